@@ -6,12 +6,16 @@ import (
 
 	"github.com/dbadoy/grinder/pkg/checkpoint"
 	"github.com/dbadoy/grinder/pkg/ethclient"
+	"github.com/dbadoy/grinder/server/dto"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 // server.Server
 type Backend interface {
 	EthClient() ethclient.Client
 	Checkpoint() checkpoint.CheckpointReader
+	Contracts(ids []string) (map[common.Address]*dto.Contract, error)
+	ContractsByABI(name string) (map[common.Address]*dto.Contract, error)
 }
 
 type service interface {
@@ -43,5 +47,6 @@ func (s *Server) Listen() {
 func SupportAPIs(b Backend) []service {
 	return []service{
 		&status{b},
+		&contract{b},
 	}
 }
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+var (
+	_ = service(&contract{})
+)
+
+type contract struct {
+	b Backend
+}
+
+func (c *contract) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.get(w, r)
+	case http.MethodPost:
+		c.post(w, r)
+	case http.MethodPut:
+		c.put(w, r)
+	case http.MethodDelete:
+		c.delete(w, r)
+	default:
+		setMethodNotAllowed(w)
+	}
+}
+
+func (contract) path() string { return "/contracts" }
+
+// get returns the contracts that satisfy the requested interface.
+//
+// /contracts?abi=<registered ABI name>
+// /contracts?id=<method or event ID>,<method or event ID>...
+func (c *contract) get(w http.ResponseWriter, r *http.Request) {
+	var (
+		query = r.URL.Query()
+
+		res interface{}
+		err error
+	)
+
+	switch {
+	case query.Has("abi"):
+		res, err = c.b.ContractsByABI(query.Get("abi"))
+	case query.Has("id"):
+		res, err = c.b.Contracts(strings.Split(query.Get("id"), ","))
+	default:
+		setBadRequest(w, []byte("either 'abi' or 'id' query is required"))
+		return
+	}
+
+	switch err {
+	case nil:
+	case database.ErrNotFound:
+		// The ABI is not registered.
+		setNotFound(w, []byte(err.Error()))
+		return
+	case database.ErrEmptyQuery:
+		setBadRequest(w, []byte(err.Error()))
+		return
+	default:
+		setInternalServerError(w, []byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		setInternalServerError(w, []byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (c *contract) post(w http.ResponseWriter, r *http.Request)   { setMethodNotAllowed(w) }
+func (c *contract) put(w http.ResponseWriter, r *http.Request)    { setMethodNotAllowed(w) }
+func (c *contract) delete(w http.ResponseWriter, r *http.Request) { setMethodNotAllowed(w) }
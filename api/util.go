@@ -11,3 +11,13 @@ func setInternalServerError(w http.ResponseWriter, detail []byte) {
 	w.WriteHeader(http.StatusInternalServerError)
 	w.Write(detail)
 }
+
+func setBadRequest(w http.ResponseWriter, detail []byte) {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(detail)
+}
+
+func setNotFound(w http.ResponseWriter, detail []byte) {
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(detail)
+}
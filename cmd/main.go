@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -13,9 +14,11 @@ import (
 	"github.com/dbadoy/grinder/pkg/database"
 	"github.com/dbadoy/grinder/pkg/database/es"
 	"github.com/dbadoy/grinder/pkg/database/memdb"
+	"github.com/dbadoy/grinder/pkg/database/pebble"
 	"github.com/dbadoy/grinder/pkg/ethclient"
 	"github.com/dbadoy/grinder/server"
 	"github.com/dbadoy/grinder/server/cft"
+	"github.com/dbadoy/grinder/server/dto"
 	"github.com/dbadoy/grinder/server/fetcher"
 )
 
@@ -24,10 +27,15 @@ func main() {
 		fetchInterval = flag.Duration("fetch", time.Second, "interval time to fetch block from ethereum")
 		ethEndpoint   = flag.String("ethendpoint", "", "ethereum endpoint url (suggest: jsonrpc)")
 		cp            = flag.String("checkpoint", "checkpoint", "checkpoint name")
-		db            = flag.String("db", "elasticsearch", "database (elasticsearch|memory)")
-		dbpath        = flag.String("dbpath", "", "database urls (url1,url2,url3...)")
+		cpstore       = flag.String("cpstore", "file", "checkpoint store (file|database)")
+		db            = flag.String("db", "elasticsearch", "database (elasticsearch|memory|pebble)")
+		dbpath        = flag.String("dbpath", "", "database urls (url1,url2,url3...), directory (pebble) or snapshot file (memory)")
+		snapshot      = flag.Duration("snapshot", time.Minute, "interval time to snapshot memory database to dbpath")
 		cluster       = flag.String("cluster", "", "cluster node list (IP:PORT,IP:PORT,IP:PORT...)")
 		http          = flag.Int("http", 0, "http listening port (0 = not support http)")
+		proxies       = flag.String("proxy", "", "proxy detectors (name1,name2...), empty uses the defaults")
+		internal      = flag.Bool("internal", false, "index contracts deployed by contracts (requires debug_traceBlockByNumber)")
+		journal       = flag.String("journal", "", "file to persist the journal to, reverted on restart (empty = in memory only)")
 	)
 	flag.Parse()
 
@@ -41,12 +49,25 @@ func main() {
 	}
 
 	// Databse
-	var database database.Database
+	var (
+		database database.Database
+		mdb      *memdb.MemoryDB
+		restored uint64
+	)
 	switch *db {
 	case "elasticsearch":
-		database, err = es.New(strings.Split(*dbpath, ","))
+		var client *es.Client
+		if client, err = es.New(strings.Split(*dbpath, ",")); err == nil {
+			// The checkpoint marker is stored in its own index,
+			// which needs the same mapping.
+			err = client.CreateIndices(append(dto.Indices, checkpoint.MarkerIndex)...)
+		}
+		database = client
 	case "memory":
-		database = memdb.New()
+		mdb, restored, err = openMemoryDB(*dbpath)
+		database = mdb
+	case "pebble":
+		database, err = pebble.New(*dbpath)
 	default:
 		err = errors.New("invalid database")
 	}
@@ -60,7 +81,19 @@ func main() {
 	}
 
 	// Checkpoint
-	checkpoint := checkpoint.New(checkpoint.DefaultBasePath, *cp)
+	checkpoint, err := openCheckpoint(*cpstore, *cp, database)
+	if err != nil {
+		panic(fmt.Errorf("checkpoint creation failed: %v", err))
+	}
+
+	// The memory database is rewound to the block its snapshot was
+	// taken at.
+	if mdb != nil && *dbpath != "" {
+		if err := checkpoint.SetCheckpoint(restored); err != nil {
+			panic(fmt.Errorf("checkpoint creation failed: %v", err))
+		}
+		checkpoint = memdb.NewSnapshotter(mdb, checkpoint, *dbpath, *snapshot)
+	}
 
 	// Cluster
 	var engine cft.Engine
@@ -93,7 +126,10 @@ func main() {
 		engine,
 		checkpoint,
 		&server.Config{
-			AllowProxyContract: true,
+			AllowProxyContract:    true,
+			ProxyDetectors:        splitNames(*proxies),
+			AllowInternalContract: *internal,
+			JournalPath:           *journal,
 		},
 	)
 
@@ -110,3 +146,52 @@ func main() {
 
 	select {}
 }
+
+// openMemoryDB restores the memory database from the snapshot at
+// path, and returns the block the snapshot was taken at. Without a
+// snapshot, ingestion restarts from 0.
+func openMemoryDB(path string) (*memdb.MemoryDB, uint64, error) {
+	if path == "" {
+		return memdb.New(), 0, nil
+	}
+
+	db, n, err := memdb.Load(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return memdb.New(), 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return db, n, nil
+}
+
+// openCheckpoint returns the checkpoint kept in the store, a file
+// under checkpoint.DefaultBasePath or the database.
+func openCheckpoint(store string, kind string, db database.Database) (checkpoint.CheckpointHandler, error) {
+	var (
+		cp  checkpoint.CheckpointHandler
+		err error
+	)
+
+	switch store {
+	case "file":
+		cp, err = checkpoint.Load(checkpoint.DefaultBasePath, kind)
+	case "database":
+		cp, err = checkpoint.NewDatabaseCheckpoint(db, kind)
+	default:
+		err = errors.New("invalid checkpoint store")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func splitNames(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
@@ -3,6 +3,7 @@ module github.com/dbadoy/grinder
 go 1.19
 
 require (
+	github.com/cockroachdb/pebble v0.0.0-20230209160836-829675f94811
 	github.com/elastic/go-elasticsearch v0.0.0
 	github.com/ethereum/go-ethereum v1.11.5
 )
@@ -16,7 +17,6 @@ require (
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/cockroachdb/errors v1.9.1 // indirect
 	github.com/cockroachdb/logtags v0.0.0-20230118201751-21c54148d20b // indirect
-	github.com/cockroachdb/pebble v0.0.0-20230209160836-829675f94811 // indirect
 	github.com/cockroachdb/redact v1.1.3 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/deckarep/golang-set/v2 v2.1.0 // indirect
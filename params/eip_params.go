@@ -4,7 +4,44 @@ const (
 	// https://eips.ethereum.org/EIPS/eip-1967
 	AdminAddressSlotEIP1967          = "0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103"
 	ImplementationAddressSlotEIP1967 = "0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc"
+	BeaconAddressSlotEIP1967         = "0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50"
+
+	// implementation() of the beacon.
+	BeaconImplementationSelector = "0x5c60da1b"
+
+	// Upgraded(address indexed implementation)
+	UpgradedEventEIP1967 = "0xbc7cd75a20ee27fd9adebab32041f755214dbc6bffa90cc0225b39da2e5c2d3b"
+	// AdminChanged(address previousAdmin, address newAdmin)
+	AdminChangedEventEIP1967 = "0x7e644d79422f17c01e4894b5f4f588d331ebfa28653d42ae832dc59e38c9798f"
+	// BeaconUpgraded(address indexed beacon)
+	BeaconUpgradedEventEIP1967 = "0x1cf3b03a6cf19fa2baba4df148e9dcabedea7f8a5c07840e207e5c089be95d3e"
 
 	// https://eips.ethereum.org/EIPS/eip-1822
 	LogicAddressSlotEIP1822 = "0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7"
+
+	// https://eips.ethereum.org/EIPS/eip-1167
+	//
+	// The runtime code is prefix + implementation address + suffix.
+	MinimalProxyPrefixEIP1167 = "0x363d3d373d3d3d363d73"
+	MinimalProxySuffixEIP1167 = "0x5af43d82803e903d91602b57fd5bf3"
+
+	// https://eips.ethereum.org/EIPS/eip-3448
+	//
+	// The runtime code is prefix + implementation address + suffix,
+	// followed by the metadata and its length.
+	MetaProxyPrefixEIP3448 = "0x363d3d373d3d3d3d60368038038091363936013d73"
+	MetaProxySuffixEIP3448 = "0x5af43d3d93803e603457fd5bf3"
+
+	// Gnosis Safe proxies keep the singleton (masterCopy) in the
+	// first slot, and answer masterCopy() themselves.
+	MasterCopySlotGnosisSafe     = "0x0000000000000000000000000000000000000000000000000000000000000000"
+	MasterCopySelectorGnosisSafe = "0xa619486e"
+
+	// keccak256("org.zeppelinos.proxy.implementation")
+	ImplementationSlotZeppelinOS = "0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036e5a723fd8ee048ed3f8c3"
+
+	// Compound's Unitroller keeps comptrollerImplementation in
+	// the third slot (after admin and pendingAdmin).
+	ComptrollerImplementationSlot     = "0x0000000000000000000000000000000000000000000000000000000000000002"
+	ComptrollerImplementationSelector = "0xbb82aa5e"
 )
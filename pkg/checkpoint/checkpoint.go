@@ -3,21 +3,45 @@ package checkpoint
 import (
 	"encoding/binary"
 	"errors"
-	"io/fs"
+	"fmt"
+	"hash/crc32"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 var (
 	DefaultBasePath = ".checkpoint"
 
+	// ErrCorrupted is returned if the checkpoint file does not match
+	// its checksum, e.g. it was torn by a crash.
+	ErrCorrupted = errors.New("corrupted checkpoint file")
+
 	defaultValue = uint64(0)
 	extension    = ".cp"
 )
 
+// The checkpoint file is
+//
+//	version (1) | number (8) | block hash (32) | crc32 (4)
+//
+// in big endian, the checksum covers the preceding bytes.
+//
+// The legacy format holds the number only, in 8 bytes. It is
+// migrated once by Load, which rewrites the file in the current
+// format. The file is only replaced as a whole since then, so a
+// torn file can't be mistaken for a legacy one.
+const (
+	version    = byte(1)
+	fileSize   = 1 + 8 + common.HashLength + 4
+	legacySize = 8
+)
+
 type CheckpointHandler interface {
 	SetCheckpoint(uint64) error
 	Increase() error
@@ -30,76 +54,203 @@ type CheckpointReader interface {
 	Checkpoint() uint64
 }
 
+// HashSetter is implemented by the handlers that record the hash of
+// the checkpoint block next to its number.
+type HashSetter interface {
+	SetCheckpointHash(n uint64, hash common.Hash) error
+}
+
+// SetCheckpointHash records the hash with the number if cp is a
+// HashSetter, otherwise only the number is set.
+func SetCheckpointHash(cp CheckpointHandler, n uint64, hash common.Hash) error {
+	if h, ok := cp.(HashSetter); ok {
+		return h.SetCheckpointHash(n, hash)
+	}
+	return cp.SetCheckpoint(n)
+}
+
+var _ HashSetter = (*Checkpoint)(nil)
+
 type Checkpoint struct {
 	path string
 	kind string
 	n    uint64
+
+	// mu serializes the writes, hash is guarded by it.
+	mu   sync.Mutex
+	hash common.Hash
 }
 
+// New works like Load, but panics if the checkpoint can't be
+// loaded.
 func New(basePath string, kind string) *Checkpoint {
-	path, err := defaultPath(basePath, runtime.GOOS)
+	cp, err := Load(basePath, kind)
 	if err != nil {
 		panic(err)
 	}
+	return cp
+}
+
+// Load reads the checkpoint of the kind under the base path. If
+// there is none, the checkpoint starts from 0. A corrupted file is
+// reported instead, a legacy file is migrated.
+func Load(basePath string, kind string) (*Checkpoint, error) {
+	path, err := defaultPath(basePath, runtime.GOOS)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{path: path, kind: kind, n: defaultValue}
 
 	if _, err := ioutil.ReadDir(path); err != nil {
 		if err := os.Mkdir(path, os.ModePerm); err != nil {
-			panic(err)
+			return nil, err
 		}
-		return &Checkpoint{path, kind, defaultValue}
+		return cp, nil
 	}
 
-	n, err := ioutil.ReadFile(filepath.Join(path, filepath.Base(kind+extension)))
+	b, err := ioutil.ReadFile(cp.file())
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
 	if err != nil {
-		return &Checkpoint{path, kind, defaultValue}
+		return nil, err
+	}
+
+	if len(b) == legacySize {
+		if err := cp.set(binary.BigEndian.Uint64(b), common.Hash{}); err != nil {
+			return nil, fmt.Errorf("checkpoint migration failed: %v", err)
+		}
+		return cp, nil
+	}
+
+	if cp.n, cp.hash, err = decode(b); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, cp.file())
 	}
 
-	return &Checkpoint{path, kind, binary.BigEndian.Uint64(n)}
+	return cp, nil
 }
 
 func (c *Checkpoint) Checkpoint() uint64 {
 	return atomic.LoadUint64(&c.n)
 }
 
+// Hash returns the hash of the checkpoint block, zero if it is not
+// known (e.g. set by SetCheckpoint, Increase or Decrease).
+func (c *Checkpoint) Hash() common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hash
+}
+
 func (c *Checkpoint) SetCheckpoint(n uint64) error {
-	if err := c.write(uint64ToBytes(n)); err != nil {
+	return c.SetCheckpointHash(n, common.Hash{})
+}
+
+func (c *Checkpoint) SetCheckpointHash(n uint64, hash common.Hash) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.set(n, hash)
+}
+
+// Increase moves to the next block, whose hash is not known. The
+// hash is cleared rather than kept for the wrong block.
+func (c *Checkpoint) Increase() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.set(atomic.LoadUint64(&c.n)+1, common.Hash{})
+}
+
+// Decrease moves to the previous block, the hash is cleared as by
+// Increase.
+func (c *Checkpoint) Decrease() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.set(atomic.LoadUint64(&c.n)-1, common.Hash{})
+}
+
+// set writes the checkpoint, the caller must hold the lock.
+func (c *Checkpoint) set(n uint64, hash common.Hash) error {
+	if err := c.write(encode(n, hash)); err != nil {
 		return err
 	}
 
 	atomic.StoreUint64(&c.n, n)
+	c.hash = hash
 	return nil
 }
 
-func (c *Checkpoint) Increase() error {
-	if err := c.write(uint64ToBytes(atomic.LoadUint64(&c.n) + 1)); err != nil {
+// write replaces the file atomically. The content is synced to a
+// temporary file first, which is then renamed over the file, so a
+// crash leaves either the previous or the new checkpoint.
+func (c *Checkpoint) write(b []byte) error {
+	var (
+		file = c.file()
+		tmp  = file + ".tmp"
+	)
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
 		return err
 	}
 
-	atomic.AddUint64(&c.n, 1)
-	return nil
-}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
 
-func (c *Checkpoint) Decrease() error {
-	n := atomic.LoadUint64(&c.n)
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
 
-	if err := c.write(uint64ToBytes(n - 1)); err != nil {
+	if err := os.Rename(tmp, file); err != nil {
 		return err
 	}
 
-	atomic.StoreUint64(&c.n, n-1)
+	// Persist the rename. Not every OS can sync a directory, the
+	// file itself is already consistent.
+	if dir, err := os.Open(c.path); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
 	return nil
 }
 
-func (c *Checkpoint) write(b []byte) error {
-	return ioutil.WriteFile(filepath.Join(c.path, filepath.Base(c.kind+extension)), b, fs.FileMode(0644))
+func (c *Checkpoint) file() string {
+	return filepath.Join(c.path, filepath.Base(c.kind+extension))
 }
 
-func uint64ToBytes(n uint64) []byte {
-	b := make([]byte, 8)
-	binary.BigEndian.PutUint64(b, n)
+func encode(n uint64, hash common.Hash) []byte {
+	b := make([]byte, fileSize)
+	b[0] = version
+	binary.BigEndian.PutUint64(b[1:], n)
+	copy(b[9:], hash[:])
+	binary.BigEndian.PutUint32(b[fileSize-4:], crc32.ChecksumIEEE(b[:fileSize-4]))
 	return b
 }
 
+func decode(b []byte) (uint64, common.Hash, error) {
+	if len(b) != fileSize || crc32.ChecksumIEEE(b[:fileSize-4]) != binary.BigEndian.Uint32(b[fileSize-4:]) {
+		return 0, common.Hash{}, ErrCorrupted
+	}
+
+	if b[0] != version {
+		return 0, common.Hash{}, fmt.Errorf("unsupported checkpoint version: %d", b[0])
+	}
+
+	return binary.BigEndian.Uint64(b[1:]), common.BytesToHash(b[9 : fileSize-4]), nil
+}
+
 // There is little possibility of adding a separate logic for each
 // OS other than the path.
 func defaultPath(base string, os string) (string, error) {
@@ -1,8 +1,12 @@
 package checkpoint
 
 import (
+	"errors"
 	"os"
 	"testing"
+
+	"github.com/dbadoy/grinder/pkg/database/pebble"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestCheckpoint(t *testing.T) {
@@ -48,3 +52,146 @@ func TestCheckpoint(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDatabaseCheckpoint(t *testing.T) {
+	var (
+		kind = "temp"
+		n    = uint64(4521)
+	)
+
+	db, err := pebble.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cp, err := NewDatabaseCheckpoint(db, kind)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Checkpoint() != 0 {
+		t.Fatalf("invalid checkpoint value, want: %v got: %v", 0, cp.Checkpoint())
+	}
+
+	if err := cp.SetCheckpoint(n); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cp.Increase(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Checkpoint() != n+1 {
+		t.Fatalf("DatabaseCheckpoint.Increase failure, want: %v got: %v", n+1, cp.Checkpoint())
+	}
+
+	// Create new object
+	obj, err := NewDatabaseCheckpoint(db, kind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Checkpoint() != n+1 {
+		t.Fatalf("invalid load checkpoint value, want: %v got: %v", n+1, obj.Checkpoint())
+	}
+
+	marker := new(Marker)
+	if err := db.Get([]byte(kindPrefix+kind), marker); err != nil || marker.Number != n+1 {
+		t.Fatalf("invalid marker, want: %v got: %v (%v)", n+1, marker.Number, err)
+	}
+
+	other, err := NewDatabaseCheckpoint(db, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Checkpoint() != 0 {
+		t.Fatalf("invalid load checkpoint value, want: %v got: %v", 0, other.Checkpoint())
+	}
+
+	// The kind of the marker key is kept apart from the marker.
+	if err := db.Put(MarkerKey, &Marker{Number: n}); err != nil {
+		t.Fatal(err)
+	}
+
+	same, err := NewDatabaseCheckpoint(db, string(MarkerKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Checkpoint() != 0 {
+		t.Fatalf("invalid load checkpoint value, want: %v got: %v", 0, same.Checkpoint())
+	}
+
+	if err := same.SetCheckpoint(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get(MarkerKey, marker); err != nil || marker.Number != n {
+		t.Fatalf("invalid marker, want: %v got: %v (%v)", n, marker.Number, err)
+	}
+}
+
+func TestCheckpointFile(t *testing.T) {
+	var (
+		kind = "file"
+		n    = uint64(4521)
+		hash = common.HexToHash("0x1234")
+	)
+
+	defer os.RemoveAll(DefaultBasePath)
+
+	cp := New(DefaultBasePath, kind)
+	if err := cp.SetCheckpointHash(n, hash); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := Load(DefaultBasePath, kind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Checkpoint() != n || obj.Hash() != hash {
+		t.Fatalf("invalid load checkpoint value, want: (%v %v) got: (%v %v)", n, hash, obj.Checkpoint(), obj.Hash())
+	}
+
+	file := cp.file()
+	if _, err := os.Stat(file + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left, want: not exist got: %v", err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy format
+	if err := os.WriteFile(file, b[1:9], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err := Load(DefaultBasePath, kind); err != nil || obj.Checkpoint() != n || obj.Hash() != (common.Hash{}) {
+		t.Fatalf("invalid legacy checkpoint, want: %v got: %v (%v)", n, obj.Checkpoint(), err)
+	}
+
+	// The legacy file is migrated by the load.
+	if migrated, _ := os.ReadFile(file); len(migrated) != fileSize {
+		t.Fatalf("invalid migrated checkpoint, want: %d bytes got: %d", fileSize, len(migrated))
+	}
+
+	// Increase and Decrease clear the hash.
+	if err := cp.SetCheckpointHash(n, hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.Increase(); err != nil || cp.Hash() != (common.Hash{}) {
+		t.Fatalf("invalid increased checkpoint hash, want: %v got: %v (%v)", common.Hash{}, cp.Hash(), err)
+	}
+
+	// Flipped bit, torn and empty files
+	flipped := append([]byte(nil), b...)
+	flipped[5] ^= 1
+
+	for _, corrupted := range [][]byte{flipped, b[:20], b[:5], b[:1], {}} {
+		if err := os.WriteFile(file, corrupted, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(DefaultBasePath, kind); !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("invalid corrupted checkpoint, want: %v got: %v", ErrCorrupted, err)
+		}
+	}
+}
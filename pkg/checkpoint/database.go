@@ -0,0 +1,77 @@
+package checkpoint
+
+import (
+	"sync"
+
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+var _ CheckpointHandler = (*DatabaseCheckpoint)(nil)
+
+// kindPrefix is prepended to the kind to key the checkpoint, so
+// no kind shares the document of the marker (MarkerKey).
+const kindPrefix = "kind:"
+
+// DatabaseCheckpoint stores the checkpoint in the database as a
+// Marker in the MarkerIndex, keyed by the kind. The progress then
+// moves together with the data, wherever the process runs.
+//
+// It is a different document from the marker committed with the
+// documents of each block, which may be ahead of it.
+type DatabaseCheckpoint struct {
+	mu  sync.RWMutex
+	db  database.Database
+	key []byte
+	n   uint64
+}
+
+func NewDatabaseCheckpoint(db database.Database, kind string) (*DatabaseCheckpoint, error) {
+	var (
+		key    = []byte(kindPrefix + kind)
+		marker = new(Marker)
+	)
+
+	if err := db.Get(key, marker); err != nil && err != database.ErrNotFound {
+		return nil, err
+	}
+
+	return &DatabaseCheckpoint{db: db, key: key, n: marker.Number}, nil
+}
+
+func (c *DatabaseCheckpoint) Checkpoint() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.n
+}
+
+func (c *DatabaseCheckpoint) SetCheckpoint(n uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.write(n)
+}
+
+func (c *DatabaseCheckpoint) Increase() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.write(c.n + 1)
+}
+
+func (c *DatabaseCheckpoint) Decrease() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.write(c.n - 1)
+}
+
+// write stores n, the caller must hold the write lock.
+func (c *DatabaseCheckpoint) write(n uint64) error {
+	if err := c.db.Put(c.key, &Marker{Number: n}); err != nil {
+		return err
+	}
+
+	c.n = n
+	return nil
+}
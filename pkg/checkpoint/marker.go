@@ -0,0 +1,19 @@
+package checkpoint
+
+// MarkerIndex is the index of the database the marker is stored in.
+const MarkerIndex = "meta"
+
+// MarkerKey is the key of the marker.
+var MarkerKey = []byte("checkpoint")
+
+// Marker is the checkpoint stored in the database, written in the
+// same batch as the documents of the block. It tells the last block
+// whose documents are stored, even if the checkpoint failed to be
+// advanced afterwards.
+type Marker struct {
+	Number uint64
+}
+
+func (Marker) Index() string {
+	return MarkerIndex
+}
@@ -0,0 +1,41 @@
+package database
+
+import "errors"
+
+// ErrAborted is the result of an operation that was not written,
+// because another operation of the atomic batch failed.
+var ErrAborted = errors.New("batch aborted")
+
+// Batch queues write operations and sends them to the database
+// together.
+type Batch interface {
+	Insert(key []byte, data Data)
+	Put(key []byte, data Data)
+	Delete(index string, key []byte)
+
+	// Len returns the number of queued operations.
+	Len() int
+
+	// Write sends the queued operations. The i-th element of the
+	// returned slice is the result of the i-th queued operation,
+	// nil on success. If the request itself fails, err is returned
+	// and the result of each operation is unknown.
+	//
+	// A batch that is written atomically writes nothing if any
+	// operation fails, the result of the others is ErrAborted.
+	Write() (results []error, err error)
+}
+
+const (
+	OpInsert = byte(1) + iota
+	OpPut
+	OpDelete
+)
+
+// Operation is a write queued in a batch.
+type Operation struct {
+	Kind  byte
+	Index string
+	Key   []byte
+	Data  Data
+}
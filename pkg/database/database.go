@@ -1,20 +1,91 @@
 package database
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+)
 
 var (
 	ErrAlreadyExist = errors.New("already exist key")
+	ErrNotFound     = errors.New("not exist key")
+	ErrInvalidRange = errors.New("negative offset or limit")
+	ErrEmptyQuery   = errors.New("query has no method or event ID")
 )
 
 type Database interface {
 	HealthCheck() error
 	Insert(key []byte, data Data) error
 	Put(key []byte, data Data) error
-	Delete(key []byte) error
+	Delete(index string, key []byte) error
 	Exist(index string, key []byte) (bool, error)
+
+	// Get reads the value stored under the key into data. The
+	// index is taken from data.Index(). If there is no value,
+	// ErrNotFound is returned.
+	Get(key []byte, data Data) error
+
+	// MultiGet returns the documents stored under the given keys in
+	// the index. Keys that do not exist are skipped.
+	MultiGet(index string, keys [][]byte) ([]*Document, error)
+
+	// Scan returns up to limit documents of the index, starting
+	// from the offset-th document in key order. A negative offset
+	// or limit is rejected with ErrInvalidRange.
+	Scan(index string, offset int, limit int) ([]*Document, error)
+
+	// Count returns the number of documents in the index.
+	Count(index string) (int, error)
+
+	// Search returns every document in the index whose field
+	// contains all of the given terms. A string field holds a
+	// single term, the whole value.
+	Search(index string, field string, terms []string) ([]*Document, error)
+
+	// NewBatch returns a batch that writes the queued operations
+	// in a single request.
+	NewBatch() Batch
 }
 
 type Data interface {
 	// Index must be lower case.
 	Index() string
 }
+
+// Document is a stored entry read back from the database. Value
+// holds the JSON encoding of the Data, use Decode to restore it.
+type Document struct {
+	Key   []byte
+	Value []byte
+}
+
+func (d *Document) Decode(data Data) error {
+	return json.Unmarshal(d.Value, data)
+}
+
+// Raw is a Data holding an encoded JSON value as is. It is used to
+// read a document back and to restore it later without knowing its
+// type.
+type Raw struct {
+	index string
+	Value json.RawMessage
+}
+
+func NewRaw(index string, value []byte) *Raw {
+	return &Raw{index, value}
+}
+
+func (r *Raw) Index() string {
+	return r.index
+}
+
+func (r *Raw) MarshalJSON() ([]byte, error) {
+	if r.Value == nil {
+		return []byte("null"), nil
+	}
+	return r.Value, nil
+}
+
+func (r *Raw) UnmarshalJSON(b []byte) error {
+	r.Value = append(r.Value[:0], b...)
+	return nil
+}
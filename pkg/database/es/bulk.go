@@ -0,0 +1,129 @@
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+var _ database.Batch = (*Bulk)(nil)
+
+// Bulk buffers writes and sends them with the _bulk API.
+type Bulk struct {
+	c   *Client
+	ops []*database.Operation
+}
+
+func (c *Client) NewBatch() database.Batch {
+	return &Bulk{c: c}
+}
+
+func (b *Bulk) Insert(key []byte, data database.Data) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpInsert, Index: data.Index(), Key: key, Data: data})
+}
+
+func (b *Bulk) Put(key []byte, data database.Data) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpPut, Index: data.Index(), Key: key, Data: data})
+}
+
+func (b *Bulk) Delete(index string, key []byte) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpDelete, Index: index, Key: key})
+}
+
+func (b *Bulk) Len() int {
+	return len(b.ops)
+}
+
+func (b *Bulk) Write() ([]error, error) {
+	if len(b.ops) == 0 {
+		return nil, nil
+	}
+
+	body, err := b.body()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.c.conn.Bulk(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var bulk struct {
+		Items []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := decodeResponse(res.StatusCode, res.Body, &bulk); err != nil {
+		return nil, err
+	}
+
+	if len(bulk.Items) != len(b.ops) {
+		return nil, fmt.Errorf("elasticsearch: bulk response has %d items, want %d", len(bulk.Items), len(b.ops))
+	}
+
+	results := make([]error, len(b.ops))
+	for i, item := range bulk.Items {
+		// Each item has a single entry keyed by its action.
+		for _, r := range item {
+			switch {
+			case r.Status < 300:
+			case r.Status == http.StatusConflict && b.ops[i].Kind == database.OpInsert:
+				results[i] = database.ErrAlreadyExist
+			case r.Status == http.StatusNotFound && b.ops[i].Kind == database.OpDelete:
+				results[i] = database.ErrNotFound
+			default:
+				results[i] = fmt.Errorf("elasticsearch: %s (%s)", http.StatusText(r.Status), r.Error)
+			}
+		}
+	}
+
+	b.ops = nil
+	return results, nil
+}
+
+// body encodes the queued operations as the newline delimited
+// JSON expected by the _bulk API.
+func (b *Bulk) body() ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, op := range b.ops {
+		var action string
+		switch op.Kind {
+		case database.OpInsert:
+			action = "create"
+		case database.OpPut:
+			action = "index"
+		case database.OpDelete:
+			action = "delete"
+		}
+
+		meta, err := json.Marshal(map[string]interface{}{
+			action: map[string]string{"_index": op.Index, "_id": string(op.Key)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(meta)
+		buf.WriteByte('\n')
+
+		if op.Data == nil {
+			continue
+		}
+
+		doc, err := encodeDocument(op.Key, op.Data)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+package es
+
+import (
+	"testing"
+
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+func TestBulk(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if err := client.Insert([]byte("exist"), &testData{[]string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := client.NewBatch()
+	batch.Insert([]byte("new"), &testData{[]string{"a"}})
+	batch.Insert([]byte("exist"), &testData{[]string{"b"}})
+	batch.Put([]byte("put"), &testData{[]string{"c"}})
+	batch.Delete("test", []byte("none"))
+
+	if batch.Len() != 4 {
+		t.Fatalf("TestBulk, want: 4 got: %d", batch.Len())
+	}
+
+	results, err := batch.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []error{nil, database.ErrAlreadyExist, nil, database.ErrNotFound}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Fatalf("TestBulk, item %d want: %v got: %v", i, want[i], results[i])
+		}
+	}
+
+	data := new(testData)
+	if err := client.Get([]byte("exist"), data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Terms[0] != "a" {
+		t.Fatalf("TestBulk, want: a got: %s", data.Terms[0])
+	}
+
+	if count, _ := client.Count("test"); count != 3 {
+		t.Fatalf("TestBulk, want: 3 got: %d", count)
+	}
+}
@@ -1,12 +1,51 @@
 package es
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
 
 	"github.com/dbadoy/grinder/pkg/database"
 	"github.com/elastic/go-elasticsearch"
 )
 
+const (
+	// searchPageSize is the number of hits fetched per scroll page.
+	searchPageSize = 1000
+
+	// maxResultWindow is the default 'index.max_result_window',
+	// from+size of a search can't exceed it.
+	maxResultWindow = 10000
+
+	// scrollKeepAlive is given in milliseconds, esapi formats the
+	// value multiplying it by time.Millisecond (= "30s").
+	scrollKeepAlive = 30000
+
+	// keyField holds the key of each document as a keyword, Scan
+	// sorts on it since '_id' can't be sorted on by default. It is
+	// left out of the sources that are read back.
+	keyField = "@key"
+
+	indexMapping = `{
+	"mappings": {
+		"properties": {
+			"@key": { "type": "keyword" }
+		},
+		"dynamic_templates": [
+			{
+				"strings": {
+					"match_mapping_type": "string",
+					"mapping": { "type": "keyword" }
+				}
+			}
+		]
+	}
+}`
+)
+
 var _ database.Database = (*Client)(nil)
 
 type Client struct {
@@ -22,22 +61,412 @@ func New(urls []string) (*Client, error) {
 }
 
 func (c *Client) HealthCheck() error {
-	_, err := c.conn.Info()
-	return err
+	res, err := c.conn.Info()
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeResponse(res.StatusCode, res.Body, nil)
+}
+
+// CreateIndices creates the given indices if they do not exist yet.
+// Every string field is mapped as a keyword, so the terms stored in
+// list fields (e.g. dto.Contract.Candidates) are matched exactly.
+func (c *Client) CreateIndices(indices ...string) error {
+	for _, index := range indices {
+		res, err := c.conn.Indices.Exists([]string{index})
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+
+		if res.StatusCode == http.StatusOK {
+			continue
+		}
+
+		res, err = c.conn.Indices.Create(index, c.conn.Indices.Create.WithBody(strings.NewReader(indexMapping)))
+		if err != nil {
+			return err
+		}
+
+		err = decodeResponse(res.StatusCode, res.Body, nil)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (c *Client) Insert(key []byte, data database.Data) error {
-	panic("need impl")
+	body, err := encodeDocument(key, data)
+	if err != nil {
+		return err
+	}
+
+	// Create fails with 409 if the document already exists
+	// (op_type=create).
+	res, err := c.conn.Create(data.Index(), string(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		return database.ErrAlreadyExist
+	}
+
+	return decodeResponse(res.StatusCode, res.Body, nil)
 }
 
 func (c *Client) Put(key []byte, data database.Data) error {
-	panic("need impl")
+	body, err := encodeDocument(key, data)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.conn.Index(data.Index(), bytes.NewReader(body), c.conn.Index.WithDocumentID(string(key)))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return decodeResponse(res.StatusCode, res.Body, nil)
 }
 
 func (c *Client) Exist(index string, key []byte) (bool, error) {
-	panic("need impl")
+	res, err := c.conn.Exists(index, string(key))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, decodeResponse(res.StatusCode, res.Body, nil)
+	}
 }
 
-func (c *Client) Delete(key []byte) error {
-	panic("need impl")
+func (c *Client) Delete(index string, key []byte) error {
+	res, err := c.conn.Delete(index, string(key))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return database.ErrNotFound
+	}
+
+	return decodeResponse(res.StatusCode, res.Body, nil)
+}
+
+func (c *Client) Get(key []byte, data database.Data) error {
+	res, err := c.conn.Get(data.Index(), string(key), c.conn.Get.WithSourceExcludes(keyField))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return database.ErrNotFound
+	}
+
+	var doc struct {
+		Found  bool            `json:"found"`
+		Source json.RawMessage `json:"_source"`
+	}
+	if err := decodeResponse(res.StatusCode, res.Body, &doc); err != nil {
+		return err
+	}
+
+	if !doc.Found {
+		return database.ErrNotFound
+	}
+
+	return json.Unmarshal(doc.Source, data)
+}
+
+func (c *Client) MultiGet(index string, keys [][]byte) ([]*database.Document, error) {
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, string(key))
+	}
+
+	body, err := json.Marshal(map[string][]string{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.conn.Mget(
+		bytes.NewReader(body),
+		c.conn.Mget.WithIndex(index),
+		c.conn.Mget.WithSourceExcludes(keyField),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var mget struct {
+		Docs []struct {
+			ID     string          `json:"_id"`
+			Found  bool            `json:"found"`
+			Source json.RawMessage `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := decodeResponse(res.StatusCode, res.Body, &mget); err != nil {
+		return nil, err
+	}
+
+	docs := make([]*database.Document, 0, len(mget.Docs))
+	for _, doc := range mget.Docs {
+		if !doc.Found {
+			continue
+		}
+		docs = append(docs, &database.Document{Key: []byte(doc.ID), Value: doc.Source})
+	}
+
+	return docs, nil
+}
+
+// Scan sorts the documents on their key and pages with from/size.
+// from+size is bounded by the 'index.max_result_window' setting of
+// the index, the documents beyond it are reached with search_after
+// from the last skipped one. Only its sort value is fetched, one
+// request per window skipped.
+func (c *Client) Scan(index string, offset int, limit int) ([]*database.Document, error) {
+	if offset < 0 || limit < 0 {
+		return nil, database.ErrInvalidRange
+	}
+
+	var (
+		docs  = make([]*database.Document, 0)
+		after []interface{}
+	)
+
+	for len(docs) < limit {
+		size := limit - len(docs)
+		if size > searchPageSize {
+			size = searchPageSize
+		}
+
+		for offset+size > maxResultWindow {
+			skip := offset
+			if skip > maxResultWindow {
+				skip = maxResultWindow
+			}
+
+			page, err := c.scan(index, skip-1, 1, after, false)
+			if err != nil {
+				return nil, err
+			}
+			if len(page.Hits.Hits) == 0 {
+				return docs, nil
+			}
+
+			after = page.Hits.Hits[0].Sort
+			offset -= skip
+		}
+
+		page, err := c.scan(index, offset, size, after, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hit := range page.Hits.Hits {
+			docs = append(docs, &database.Document{Key: []byte(hit.ID), Value: hit.Source})
+		}
+
+		if len(page.Hits.Hits) < size {
+			break
+		}
+		after = page.Hits.Hits[len(page.Hits.Hits)-1].Sort
+		offset = 0
+	}
+
+	return docs, nil
+}
+
+// scan requests a page of the documents sorted on their key, after
+// the given sort value. Without the source only the keys and the
+// sort values are returned.
+func (c *Client) scan(index string, from int, size int, after []interface{}, source bool) (*searchResponse, error) {
+	req := map[string]interface{}{
+		"from": from,
+		"size": size,
+		"sort": []interface{}{map[string]string{keyField: "asc"}},
+	}
+	if after != nil {
+		req["search_after"] = after
+	}
+	if !source {
+		req["_source"] = false
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.conn.Search(
+		c.conn.Search.WithIndex(index),
+		c.conn.Search.WithBody(bytes.NewReader(body)),
+		c.conn.Search.WithSourceExcludes(keyField),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	page := new(searchResponse)
+	if err := decodeResponse(res.StatusCode, res.Body, page); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (c *Client) Count(index string) (int, error) {
+	res, err := c.conn.Count(c.conn.Count.WithIndex(index))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	var count struct {
+		Count int `json:"count"`
+	}
+	if err := decodeResponse(res.StatusCode, res.Body, &count); err != nil {
+		return 0, err
+	}
+
+	return count.Count, nil
+}
+
+func (c *Client) Search(index string, field string, terms []string) ([]*database.Document, error) {
+	filter := make([]interface{}, 0, len(terms))
+	for _, term := range terms {
+		filter = append(filter, map[string]interface{}{
+			"term": map[string]string{field: term},
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"filter": filter},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.conn.Search(
+		c.conn.Search.WithIndex(index),
+		c.conn.Search.WithBody(bytes.NewReader(body)),
+		c.conn.Search.WithSize(searchPageSize),
+		c.conn.Search.WithScroll(scrollKeepAlive),
+		c.conn.Search.WithSourceExcludes(keyField),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		docs = make([]*database.Document, 0)
+		page searchResponse
+	)
+
+	err = decodeResponse(res.StatusCode, res.Body, &page)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if page.ScrollID != "" {
+			if res, err := c.conn.ClearScroll(c.conn.ClearScroll.WithScrollID(page.ScrollID)); err == nil {
+				res.Body.Close()
+			}
+		}
+	}()
+
+	for len(page.Hits.Hits) != 0 {
+		for _, hit := range page.Hits.Hits {
+			docs = append(docs, &database.Document{Key: []byte(hit.ID), Value: hit.Source})
+		}
+
+		res, err := c.conn.Scroll(
+			c.conn.Scroll.WithScrollID(page.ScrollID),
+			c.conn.Scroll.WithScroll(scrollKeepAlive),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		page = searchResponse{}
+		err = decodeResponse(res.StatusCode, res.Body, &page)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return docs, nil
+}
+
+type searchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Hits []struct {
+			ID     string          `json:"_id"`
+			Source json.RawMessage `json:"_source"`
+			Sort   []interface{}   `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// encodeDocument encodes the data with its key in the keyField.
+func encodeDocument(key []byte, data database.Data) ([]byte, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) < 2 || body[0] != '{' {
+		return nil, fmt.Errorf("elasticsearch: document of %s is not a JSON object", data.Index())
+	}
+
+	field, err := json.Marshal(map[string]string{keyField: string(key)})
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge the two objects: '{"@key":"..."' + ',' + '...}'.
+	fields := bytes.TrimSpace(body[1:])
+	if len(fields) == 1 {
+		return field, nil
+	}
+	return append(append(field[:len(field)-1], ','), fields...), nil
+}
+
+// decodeResponse decodes the body of a successful response into v,
+// otherwise returns the error reported by Elasticsearch.
+func decodeResponse(status int, body io.Reader, v interface{}) error {
+	if status > 299 {
+		b, _ := io.ReadAll(body)
+		return fmt.Errorf("elasticsearch: %s (%s)", http.StatusText(status), b)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(body).Decode(v)
 }
@@ -0,0 +1,524 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+// fakeES is a stand-in for the subset of the Elasticsearch REST API
+// used by Client.
+type fakeES struct {
+	mu      sync.Mutex
+	indices map[string]map[string]json.RawMessage
+
+	// searches counts the search requests.
+	searches int
+}
+
+func newFakeES() *fakeES {
+	return &fakeES{indices: make(map[string]map[string]json.RawMessage)}
+}
+
+func (f *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.URL.Path == "/":
+		writeJSON(w, http.StatusOK, map[string]string{"cluster_name": "fake"})
+
+	case parts[0] == "_bulk":
+		f.serveBulk(w, body)
+
+	case parts[0] == "_search" && len(parts) > 1 && parts[1] == "scroll":
+		// Every hit is returned on the first page.
+		writeJSON(w, http.StatusOK, map[string]interface{}{"hits": map[string]interface{}{"hits": []interface{}{}}})
+
+	case len(parts) == 1:
+		f.serveIndex(w, r, parts[0])
+
+	case parts[1] == "_doc":
+		f.serveDoc(w, r, parts[0], parts[2], len(parts) == 4 && parts[3] == "_create", body)
+
+	case parts[1] == "_search":
+		f.serveSearch(w, r, parts[0], body)
+
+	case parts[1] == "_mget":
+		var req struct {
+			IDs []string `json:"ids"`
+		}
+		json.Unmarshal(body, &req)
+
+		docs := make([]interface{}, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			source, ok := f.indices[parts[0]][id]
+			docs = append(docs, map[string]interface{}{"_id": id, "found": ok, "_source": excludeFields(r, source)})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"docs": docs})
+
+	case parts[1] == "_count":
+		writeJSON(w, http.StatusOK, map[string]int{"count": len(f.indices[parts[0]])})
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeES) serveIndex(w http.ResponseWriter, r *http.Request, index string) {
+	_, ok := f.indices[index]
+
+	switch r.Method {
+	case http.MethodHead:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodPut:
+		if ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "resource_already_exists_exception"})
+			return
+		}
+		f.indices[index] = make(map[string]json.RawMessage)
+		writeJSON(w, http.StatusOK, map[string]bool{"acknowledged": true})
+	}
+}
+
+func (f *fakeES) serveDoc(w http.ResponseWriter, r *http.Request, index string, id string, create bool, body []byte) {
+	docs, ok := f.indices[index]
+	if !ok {
+		// Elasticsearch creates the index on the first write.
+		docs = make(map[string]json.RawMessage)
+		f.indices[index] = docs
+	}
+	source, exist := docs[id]
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		if create && exist {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "version_conflict_engine_exception"})
+			return
+		}
+		docs[id] = body
+		writeJSON(w, http.StatusCreated, map[string]string{"result": "created"})
+
+	case http.MethodHead:
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodGet:
+		if !exist {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"_id": id, "found": false})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"_id": id, "found": true, "_source": excludeFields(r, source)})
+
+	case http.MethodDelete:
+		if !exist {
+			writeJSON(w, http.StatusNotFound, map[string]string{"result": "not_found"})
+			return
+		}
+		delete(docs, id)
+		writeJSON(w, http.StatusOK, map[string]string{"result": "deleted"})
+	}
+}
+
+func (f *fakeES) serveBulk(w http.ResponseWriter, body []byte) {
+	var (
+		lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+		items = make([]interface{}, 0)
+	)
+
+	for i := 0; i < len(lines); i++ {
+		var meta map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		json.Unmarshal([]byte(lines[i]), &meta)
+
+		for action, m := range meta {
+			docs, ok := f.indices[m.Index]
+			if !ok {
+				docs = make(map[string]json.RawMessage)
+				f.indices[m.Index] = docs
+			}
+			_, exist := docs[m.ID]
+
+			status := http.StatusOK
+			switch action {
+			case "create", "index":
+				i++
+				if action == "create" && exist {
+					status = http.StatusConflict
+					break
+				}
+				docs[m.ID] = json.RawMessage(lines[i])
+			case "delete":
+				if !exist {
+					status = http.StatusNotFound
+					break
+				}
+				delete(docs, m.ID)
+			}
+
+			items = append(items, map[string]interface{}{action: map[string]interface{}{"_id": m.ID, "status": status}})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (f *fakeES) serveSearch(w http.ResponseWriter, r *http.Request, index string, body []byte) {
+	var req struct {
+		Query struct {
+			Bool struct {
+				Filter []struct {
+					Term map[string]string `json:"term"`
+				} `json:"filter"`
+			} `json:"bool"`
+		} `json:"query"`
+		From        int                 `json:"from"`
+		Size        *int                `json:"size"`
+		Sort        []map[string]string `json:"sort"`
+		SearchAfter []string            `json:"search_after"`
+		Source      *bool               `json:"_source"`
+	}
+	json.Unmarshal(body, &req)
+
+	f.searches++
+
+	// Only an ascending sort on a keyword field is supported.
+	var sortField string
+	for _, s := range req.Sort {
+		for field := range s {
+			sortField = field
+		}
+	}
+
+	var (
+		ids    = make([]string, 0)
+		values = make(map[string]string)
+	)
+	for id, source := range f.indices[index] {
+		var fields map[string]interface{}
+		json.Unmarshal(source, &fields)
+
+		match := true
+		if sortField != "" {
+			value, ok := fields[sortField].(string)
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no mapping found for " + sortField})
+				return
+			}
+			values[id] = value
+
+			if len(req.SearchAfter) != 0 && value <= req.SearchAfter[0] {
+				match = false
+			}
+		}
+
+		for _, filter := range req.Query.Bool.Filter {
+			for field, term := range filter.Term {
+				if !contains(fields[field], term) {
+					match = false
+				}
+			}
+		}
+
+		if match {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if sortField != "" {
+			return values[ids[i]] < values[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	// The default size of a page is 10.
+	size := 10
+	if req.Size != nil {
+		size = *req.Size
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil {
+		size = n
+	}
+	if req.From+size > maxResultWindow {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "result window is too large"})
+		return
+	}
+
+	if req.From > len(ids) {
+		req.From = len(ids)
+	}
+	ids = ids[req.From:]
+	if size < len(ids) {
+		ids = ids[:size]
+	}
+
+	hits := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		hit := map[string]interface{}{"_id": id}
+		if req.Source == nil || *req.Source {
+			hit["_source"] = excludeFields(r, f.indices[index][id])
+		}
+		if sortField != "" {
+			hit["sort"] = []string{values[id]}
+		}
+		hits = append(hits, hit)
+	}
+
+	res := map[string]interface{}{"hits": map[string]interface{}{"hits": hits}}
+	if r.URL.Query().Has("scroll") {
+		res["_scroll_id"] = "scroll"
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// excludeFields removes the fields in the '_source_excludes'
+// parameter from the source.
+func excludeFields(r *http.Request, source json.RawMessage) json.RawMessage {
+	excludes := r.URL.Query().Get("_source_excludes")
+	if excludes == "" || source == nil {
+		return source
+	}
+
+	var fields map[string]json.RawMessage
+	json.Unmarshal(source, &fields)
+	for _, field := range strings.Split(excludes, ",") {
+		delete(fields, field)
+	}
+
+	b, _ := json.Marshal(fields)
+	return b
+}
+
+func contains(field interface{}, term string) bool {
+	values, ok := field.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, v := range values {
+		if v == term {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+type testData struct {
+	Terms []string
+}
+
+func (testData) Index() string { return "test" }
+
+func newTestClient(t *testing.T) (*Client, *fakeES) {
+	fake := newFakeES()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client, err := New([]string{srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	return client, fake
+}
+
+func TestCreateIndices(t *testing.T) {
+	client, fake := newTestClient(t)
+
+	if err := client.CreateIndices("test", "abis"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing indices must be skipped.
+	if err := client.CreateIndices("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.indices) != 2 {
+		t.Fatalf("TestCreateIndices, want: 2 got: %d", len(fake.indices))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if err := client.CreateIndices("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	key := []byte("0x01")
+
+	if err := client.Insert(key, &testData{[]string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Insert(key, &testData{[]string{"b"}}); err != database.ErrAlreadyExist {
+		t.Fatalf("TestWrite, want: %v got: %v", database.ErrAlreadyExist, err)
+	}
+
+	if ok, err := client.Exist("test", key); err != nil || !ok {
+		t.Fatalf("TestWrite, want: exist got: %v (%v)", ok, err)
+	}
+
+	if err := client.Put(key, &testData{[]string{"b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := new(testData)
+	if err := client.Get(key, data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Terms) != 1 || data.Terms[0] != "b" {
+		t.Fatalf("TestWrite, want: [b] got: %v", data.Terms)
+	}
+
+	if err := client.Delete("test", key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Delete("test", key); err != database.ErrNotFound {
+		t.Fatalf("TestWrite, want: %v got: %v", database.ErrNotFound, err)
+	}
+
+	if ok, err := client.Exist("test", key); err != nil || ok {
+		t.Fatalf("TestWrite, want: not exist got: %v (%v)", ok, err)
+	}
+
+	if err := client.Get(key, data); err != database.ErrNotFound {
+		t.Fatalf("TestWrite, want: %v got: %v", database.ErrNotFound, err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	n := 10
+	for i := 0; i < n; i++ {
+		terms := []string{"all", fmt.Sprintf("%d", i%2)}
+		if err := client.Insert([]byte(fmt.Sprintf("key-%d", i)), &testData{terms}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs, err := client.MultiGet("test", [][]byte{[]byte("key-1"), []byte("key-2"), []byte("key-none")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("TestRead, want: 2 got: %d", len(docs))
+	}
+
+	if count, err := client.Count("test"); err != nil || count != n {
+		t.Fatalf("TestRead, want: %d got: %d (%v)", n, count, err)
+	}
+
+	docs, err = client.Scan("test", 8, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("TestRead, want: 2 got: %d", len(docs))
+	}
+
+	docs, err = client.Search("test", "Terms", []string{"all", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != n/2 {
+		t.Fatalf("TestRead, want: %d got: %d", n/2, len(docs))
+	}
+
+	data := new(testData)
+	if err := docs[0].Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Terms[1] != "1" {
+		t.Fatalf("TestRead, want: 1 got: %s", data.Terms[1])
+	}
+}
+
+func TestScan(t *testing.T) {
+	client, fake := newTestClient(t)
+
+	// Beyond the result window, the keys are zero padded to keep
+	// the order.
+	n := maxResultWindow + 2*searchPageSize + 5
+
+	batch := client.NewBatch()
+	for i := 0; i < n; i++ {
+		batch.Insert([]byte(fmt.Sprintf("key-%05d", i)), &testData{[]string{"all"}})
+	}
+	if _, err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(fake.indices["test"]["key-00000"]), keyField) {
+		t.Fatalf("TestScan, want: %s stored got: %s", keyField, fake.indices["test"]["key-00000"])
+	}
+
+	for _, tc := range []struct {
+		offset, limit int
+		want          int
+		searches      int
+	}{
+		{searchPageSize + 3, searchPageSize, searchPageSize, 1},
+		{maxResultWindow + 3, 5, 5, 2},
+		{n - 2, 5, 2, 2},
+		{0, n, n, n/searchPageSize + 1},
+	} {
+		fake.searches = 0
+
+		docs, err := client.Scan("test", tc.offset, tc.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) != tc.want || fake.searches != tc.searches {
+			t.Fatalf("TestScan, want: (%d %d) got: (%d %d)", tc.want, tc.searches, len(docs), fake.searches)
+		}
+
+		for i, doc := range docs {
+			if want := fmt.Sprintf("key-%05d", tc.offset+i); string(doc.Key) != want {
+				t.Fatalf("TestScan, want: %s got: %s", want, doc.Key)
+			}
+			if strings.Contains(string(doc.Value), keyField) {
+				t.Fatalf("TestScan, want: no %s got: %s", keyField, doc.Value)
+			}
+		}
+	}
+
+	if _, err := client.Scan("test", -1, 5); err != database.ErrInvalidRange {
+		t.Fatalf("TestScan, want: %v got: %v", database.ErrInvalidRange, err)
+	}
+}
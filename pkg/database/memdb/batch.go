@@ -0,0 +1,97 @@
+package memdb
+
+import "github.com/dbadoy/grinder/pkg/database"
+
+// Batch queues writes and applies them under a single lock, so a
+// reader sees either none or all of them. If any operation fails
+// (e.g. an insert of an existing key), nothing is applied.
+type Batch struct {
+	m   *MemoryDB
+	ops []*database.Operation
+}
+
+func (m *MemoryDB) NewBatch() database.Batch {
+	return &Batch{m: m}
+}
+
+func (b *Batch) Insert(key []byte, data database.Data) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpInsert, Index: data.Index(), Key: key, Data: data})
+}
+
+func (b *Batch) Put(key []byte, data database.Data) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpPut, Index: data.Index(), Key: key, Data: data})
+}
+
+func (b *Batch) Delete(index string, key []byte) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpDelete, Index: index, Key: key})
+}
+
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+func (b *Batch) Write() ([]error, error) {
+	// The data is encoded before the lock is taken, so that an
+	// invalid value fails the whole batch.
+	values := make([][]byte, len(b.ops))
+	for i, op := range b.ops {
+		value, err := encode(op)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	b.m.mu.Lock()
+	defer b.m.mu.Unlock()
+
+	ops := b.ops
+	b.ops = nil
+
+	// The operations are checked against the data and the ones
+	// queued before, so that none is applied if any fails.
+	var (
+		results = make([]error, len(ops))
+		exist   = make(map[string]bool)
+		failed  = false
+	)
+	for i, op := range ops {
+		id := op.Index + "\x00" + string(op.Key)
+
+		ok, seen := exist[id]
+		if !seen {
+			ok = b.m.exist(op.Index, op.Key)
+		}
+
+		switch {
+		case op.Kind == database.OpInsert && ok:
+			results[i] = database.ErrAlreadyExist
+		case op.Kind == database.OpDelete && !ok:
+			results[i] = database.ErrNotFound
+		case op.Kind != database.OpDelete:
+			_, results[i] = terms(values[i])
+		}
+
+		if results[i] != nil {
+			failed = true
+			continue
+		}
+		exist[id] = op.Kind != database.OpDelete
+	}
+
+	if failed {
+		for i, res := range results {
+			if res == nil {
+				results[i] = database.ErrAborted
+			}
+		}
+		return results, nil
+	}
+
+	for i, op := range ops {
+		// Checked above, it can't fail here.
+		b.m.apply(op, values[i])
+	}
+
+	return results, nil
+}
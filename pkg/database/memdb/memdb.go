@@ -1,35 +1,56 @@
 package memdb
 
 import (
-	"errors"
+	"encoding/json"
+	"sort"
 	"sync"
 
 	"github.com/dbadoy/grinder/pkg/database"
 )
 
-var _ database.Database = (*MemoryDB)(nil)
+var (
+	_ database.Database = (*MemoryDB)(nil)
+	_ database.Batch    = (*Batch)(nil)
+)
 
+// MemoryDB is an in-memory inverted index. Documents are kept
+// encoded per index (Data.Index()), and every term of their list
+// fields (e.g. the method and event IDs of dto.Contract.Candidates)
+// has a posting set of the keys containing it.
 type MemoryDB struct {
-	mu sync.Mutex
-	v  map[interface{}]database.Data
+	mu      sync.RWMutex
+	indices map[string]*index
 }
 
-func New() *MemoryDB {
-	return &MemoryDB{v: make(map[interface{}]database.Data)}
+type index struct {
+	docs map[string][]byte
+
+	// postings maps a field and one of its terms to the set of
+	// keys whose document contains the term.
+	postings map[string]map[string]map[string]struct{}
 }
 
-func (m *MemoryDB) Get(key []byte) database.Data {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	
-	return m.v[string(key)]
+func newIndex() *index {
+	return &index{
+		docs:     make(map[string][]byte),
+		postings: make(map[string]map[string]map[string]struct{}),
+	}
+}
+
+func New() *MemoryDB {
+	return &MemoryDB{indices: make(map[string]*index)}
 }
 
+// Size returns the number of documents in all indices.
 func (m *MemoryDB) Size() int {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	return len(m.v)
+	var n int
+	for _, idx := range m.indices {
+		n += len(idx.docs)
+	}
+	return n
 }
 
 // database.Database
@@ -38,45 +59,294 @@ func (m *MemoryDB) HealthCheck() error {
 }
 
 func (m *MemoryDB) Insert(key []byte, data database.Data) error {
+	return m.write(&database.Operation{Kind: database.OpInsert, Index: data.Index(), Key: key, Data: data})
+}
+
+func (m *MemoryDB) Put(key []byte, data database.Data) error {
+	return m.write(&database.Operation{Kind: database.OpPut, Index: data.Index(), Key: key, Data: data})
+}
+
+func (m *MemoryDB) Delete(index string, key []byte) error {
+	return m.write(&database.Operation{Kind: database.OpDelete, Index: index, Key: key})
+}
+
+func (m *MemoryDB) Exist(index string, key []byte) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.exist(index, key), nil
+}
+
+// exist reports whether the key is stored, the caller must hold
+// the lock.
+func (m *MemoryDB) exist(index string, key []byte) bool {
+	idx, ok := m.indices[index]
+	if !ok {
+		return false
+	}
+
+	_, ok = idx.docs[string(key)]
+	return ok
+}
+
+func (m *MemoryDB) Get(key []byte, data database.Data) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx, ok := m.indices[data.Index()]
+	if !ok {
+		return database.ErrNotFound
+	}
+
+	value, ok := idx.docs[string(key)]
+	if !ok {
+		return database.ErrNotFound
+	}
+
+	return json.Unmarshal(value, data)
+}
+
+func (m *MemoryDB) MultiGet(index string, keys [][]byte) ([]*database.Document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*database.Document, 0, len(keys))
+
+	idx, ok := m.indices[index]
+	if !ok {
+		return res, nil
+	}
+
+	for _, key := range keys {
+		if value, ok := idx.docs[string(key)]; ok {
+			res = append(res, &database.Document{Key: key, Value: value})
+		}
+	}
+
+	return res, nil
+}
+
+func (m *MemoryDB) Scan(index string, offset int, limit int) ([]*database.Document, error) {
+	if offset < 0 || limit < 0 {
+		return nil, database.ErrInvalidRange
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx, ok := m.indices[index]
+	if !ok {
+		return []*database.Document{}, nil
+	}
+
+	keys := make([]string, 0, len(idx.docs))
+	for k := range idx.docs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if offset >= len(keys) {
+		return []*database.Document{}, nil
+	}
+
+	keys = keys[offset:]
+	if limit < len(keys) {
+		keys = keys[:limit]
+	}
+
+	res := make([]*database.Document, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, &database.Document{Key: []byte(k), Value: idx.docs[k]})
+	}
+
+	return res, nil
+}
+
+func (m *MemoryDB) Count(index string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if idx, ok := m.indices[index]; ok {
+		return len(idx.docs), nil
+	}
+	return 0, nil
+}
+
+// Search intersects the posting sets of the terms, starting from
+// the smallest one.
+func (m *MemoryDB) Search(index string, field string, terms []string) ([]*database.Document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*database.Document, 0)
+
+	idx, ok := m.indices[index]
+	if !ok || len(terms) == 0 {
+		return res, nil
+	}
+
+	sets := make([]map[string]struct{}, 0, len(terms))
+	for _, term := range terms {
+		set, ok := idx.postings[field][term]
+		if !ok {
+			return res, nil
+		}
+		sets = append(sets, set)
+	}
+
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	for k := range sets[0] {
+		match := true
+		for _, set := range sets[1:] {
+			if _, ok := set[k]; !ok {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			res = append(res, &database.Document{Key: []byte(k), Value: idx.docs[k]})
+		}
+	}
+
+	return res, nil
+}
+
+// write applies a single operation.
+func (m *MemoryDB) write(op *database.Operation) error {
+	value, err := encode(op)
+	if err != nil {
+		return err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	lk := string(key)
+	return m.apply(op, value)
+}
+
+// encode returns the JSON encoding of the data of the operation,
+// nil for a delete.
+func encode(op *database.Operation) ([]byte, error) {
+	if op.Kind == database.OpDelete {
+		return nil, nil
+	}
+	return json.Marshal(op.Data)
+}
+
+// apply applies the operation with the encoded data. The caller
+// must hold the write lock.
+func (m *MemoryDB) apply(op *database.Operation, value []byte) error {
+	if op.Kind == database.OpDelete {
+		idx, ok := m.indices[op.Index]
+		if !ok {
+			return database.ErrNotFound
+		}
+
+		if _, ok := idx.docs[string(op.Key)]; !ok {
+			return database.ErrNotFound
+		}
 
-	if _, ok := m.v[lk]; ok {
+		idx.delete(string(op.Key))
+		return nil
+	}
+
+	idx := m.index(op.Index)
+
+	if _, ok := idx.docs[string(op.Key)]; ok && op.Kind == database.OpInsert {
 		return database.ErrAlreadyExist
 	}
 
-	m.v[lk] = data
-	return nil
+	return idx.set(string(op.Key), value)
 }
 
-func (m *MemoryDB) Put(key []byte, data database.Data) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// index returns the index, creating it if it does not exist. The
+// caller must hold the write lock.
+func (m *MemoryDB) index(name string) *index {
+	idx, ok := m.indices[name]
+	if !ok {
+		idx = newIndex()
+		m.indices[name] = idx
+	}
+	return idx
+}
+
+// set stores the encoded document and replaces the postings of
+// the previous value.
+func (idx *index) set(key string, value []byte) error {
+	fields, err := terms(value)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := idx.docs[key]; ok {
+		idx.delete(key)
+	}
+
+	idx.docs[key] = value
+
+	for field, values := range fields {
+		postings, ok := idx.postings[field]
+		if !ok {
+			postings = make(map[string]map[string]struct{})
+			idx.postings[field] = postings
+		}
+
+		for _, term := range values {
+			set, ok := postings[term]
+			if !ok {
+				set = make(map[string]struct{})
+				postings[term] = set
+			}
+			set[key] = struct{}{}
+		}
+	}
 
-	m.v[string(key)] = data
 	return nil
 }
 
-func (m *MemoryDB) Delete(key []byte) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (idx *index) delete(key string) {
+	// The stored value has been decoded once before, so it can't
+	// fail here.
+	fields, _ := terms(idx.docs[key])
 
-	lk := string(key)
+	for field, values := range fields {
+		for _, term := range values {
+			set := idx.postings[field][term]
+			delete(set, key)
 
-	if _, ok := m.v[lk]; !ok {
-		return errors.New("not exist key")
+			if len(set) == 0 {
+				delete(idx.postings[field], term)
+			}
+		}
 	}
 
-	delete(m.v, lk)
-	return nil
+	delete(idx.docs, key)
 }
 
-func (m *MemoryDB) Exist(_ string, key []byte) (bool, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// terms returns the list and string fields of the encoded
+// document.
+func terms(value []byte) (map[string][]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]string)
+	for field, raw := range fields {
+		var terms []string
+		if err := json.Unmarshal(raw, &terms); err == nil {
+			res[field] = terms
+			continue
+		}
+
+		// A string field holds a single term.
+		var term string
+		if err := json.Unmarshal(raw, &term); err == nil && term != "" {
+			res[field] = []string{term}
+		}
+	}
 
-	_, ok := m.v[string(key)]
-	return ok, nil
+	return res, nil
 }
@@ -0,0 +1,247 @@
+package memdb
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+type testData struct {
+	Terms []string
+}
+
+func (testData) Index() string { return "test" }
+
+type namedData struct {
+	Name string
+}
+
+func (namedData) Index() string { return "named" }
+
+type otherData struct {
+	Terms []string
+}
+
+func (otherData) Index() string { return "other" }
+
+func TestRead(t *testing.T) {
+	var (
+		db = New()
+		n  = 10
+	)
+
+	for i := 0; i < n; i++ {
+		terms := []string{"all", fmt.Sprintf("%d", i%2)}
+		if err := db.Insert([]byte(fmt.Sprintf("key-%d", i)), &testData{terms}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Insert([]byte("key-other"), &otherData{[]string{"all"}})
+
+	data := new(testData)
+	if err := db.Get([]byte("key-3"), data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Terms) != 2 || data.Terms[1] != "1" {
+		t.Fatalf("TestRead, want: [all 1] got: %v", data.Terms)
+	}
+
+	if err := db.Get([]byte("key-other"), new(testData)); err != database.ErrNotFound {
+		t.Fatalf("TestRead, want: %v got: %v", database.ErrNotFound, err)
+	}
+
+	docs, err := db.MultiGet("test", [][]byte{[]byte("key-1"), []byte("key-2"), []byte("key-none")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || string(docs[0].Key) != "key-1" {
+		t.Fatalf("TestRead, want: 2 got: %d", len(docs))
+	}
+
+	if count, _ := db.Count("test"); count != n {
+		t.Fatalf("TestRead, want: %d got: %d", n, count)
+	}
+
+	var scanned int
+	for offset := 0; ; offset += 3 {
+		docs, err := db.Scan("test", offset, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+		scanned += len(docs)
+	}
+	if scanned != n {
+		t.Fatalf("TestRead, want: %d got: %d", n, scanned)
+	}
+
+	if _, err := db.Scan("test", -1, 3); err != database.ErrInvalidRange {
+		t.Fatalf("TestRead, want: %v got: %v", database.ErrInvalidRange, err)
+	}
+	if _, err := db.Scan("test", 0, -1); err != database.ErrInvalidRange {
+		t.Fatalf("TestRead, want: %v got: %v", database.ErrInvalidRange, err)
+	}
+
+	docs, err = db.Search("test", "Terms", []string{"all", "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != n/2 {
+		t.Fatalf("TestRead, want: %d got: %d", n/2, len(docs))
+	}
+
+	if err := docs[0].Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Terms[1] != "0" {
+		t.Fatalf("TestRead, want: 0 got: %s", data.Terms[1])
+	}
+}
+
+func TestIndexNamespace(t *testing.T) {
+	var (
+		db  = New()
+		key = []byte("0x0000000000000000000000000000000000000001")
+	)
+
+	if err := db.Insert(key, &testData{[]string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same key in another index must not collide.
+	if err := db.Insert(key, &otherData{[]string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Delete("other", key); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := db.Exist("test", key); !ok {
+		t.Fatal("TestIndexNamespace, want: exist got: not exist")
+	}
+
+	if ok, _ := db.Exist("other", key); ok {
+		t.Fatal("TestIndexNamespace, want: not exist got: exist")
+	}
+}
+
+func TestPostings(t *testing.T) {
+	var (
+		db  = New()
+		key = []byte("key")
+	)
+
+	if err := db.Insert(key, &testData{[]string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Overwriting must replace the postings of the previous value.
+	if err := db.Put(key, &testData{[]string{"b", "c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"a"}); len(docs) != 0 {
+		t.Fatalf("TestPostings, want: 0 got: %d", len(docs))
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"b", "c"}); len(docs) != 1 {
+		t.Fatalf("TestPostings, want: 1 got: %d", len(docs))
+	}
+
+	if err := db.Delete("test", key); err != nil {
+		t.Fatal(err)
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"b"}); len(docs) != 0 {
+		t.Fatalf("TestPostings, want: 0 got: %d", len(docs))
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db := New()
+
+	if err := db.Insert([]byte("exist"), &testData{[]string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := db.NewBatch()
+	batch.Insert([]byte("new"), &testData{[]string{"a"}})
+	batch.Insert([]byte("new"), &testData{[]string{"b"}})
+	batch.Insert([]byte("exist"), &testData{[]string{"b"}})
+	batch.Put([]byte("put"), &testData{[]string{"c"}})
+	batch.Delete("test", []byte("none"))
+
+	if batch.Len() != 5 {
+		t.Fatalf("TestBatch, want: 5 got: %d", batch.Len())
+	}
+
+	results, err := batch.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []error{database.ErrAborted, database.ErrAlreadyExist, database.ErrAlreadyExist, database.ErrAborted, database.ErrNotFound}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Fatalf("TestBatch, item %d want: %v got: %v", i, want[i], results[i])
+		}
+	}
+
+	// Nothing is written if any operation fails.
+	if db.Size() != 1 {
+		t.Fatalf("TestBatch, want: 1 got: %d", db.Size())
+	}
+
+	batch.Insert([]byte("new"), &testData{[]string{"a"}})
+	batch.Put([]byte("put"), &testData{[]string{"c"}})
+	batch.Delete("test", []byte("exist"))
+
+	results, err = batch.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, res := range results {
+		if res != nil {
+			t.Fatalf("TestBatch, item %d want: <nil> got: %v", i, res)
+		}
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"a"}); len(docs) != 1 {
+		t.Fatalf("TestBatch, want: 1 got: %d", len(docs))
+	}
+
+	if db.Size() != 2 {
+		t.Fatalf("TestBatch, want: 2 got: %d", db.Size())
+	}
+}
+
+func TestSearchString(t *testing.T) {
+	db := New()
+
+	for i, name := range []string{"a", "b", "a", ""} {
+		if err := db.Insert([]byte(fmt.Sprintf("key-%d", i)), &namedData{name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs, err := db.Search("named", "Name", []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("TestSearchString, want: 2 got: %d", len(docs))
+	}
+
+	if err := db.Put([]byte("key-0"), &namedData{"b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if docs, _ := db.Search("named", "Name", []string{"a"}); len(docs) != 1 {
+		t.Fatalf("TestSearchString, want: 1 got: %d", len(docs))
+	}
+}
@@ -0,0 +1,175 @@
+package memdb
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dbadoy/grinder/pkg/checkpoint"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	_ checkpoint.CheckpointHandler = (*Snapshotter)(nil)
+	_ checkpoint.HashSetter        = (*Snapshotter)(nil)
+)
+
+type snapshot struct {
+	// Checkpoint is the block number the data was ingested up to.
+	Checkpoint uint64
+	Indices    map[string]map[string]json.RawMessage
+}
+
+// Snapshot dumps the data to the file at path together with the
+// given checkpoint. The file is replaced atomically, a crash while
+// writing leaves the previous snapshot intact.
+func (m *MemoryDB) Snapshot(path string, cp uint64) error {
+	m.mu.RLock()
+	snap := &snapshot{Checkpoint: cp, Indices: make(map[string]map[string]json.RawMessage, len(m.indices))}
+	for name, idx := range m.indices {
+		docs := make(map[string]json.RawMessage, len(idx.docs))
+		for k, v := range idx.docs {
+			docs[k] = v
+		}
+		snap.Indices[name] = docs
+	}
+	b, err := json.Marshal(snap)
+	m.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load restores a MemoryDB from the snapshot at path, and returns
+// the checkpoint the snapshot was taken at.
+func Load(path string) (*MemoryDB, uint64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, 0, err
+	}
+
+	m := New()
+	for name, docs := range snap.Indices {
+		idx := m.index(name)
+		for k, v := range docs {
+			if err := idx.set(k, v); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	return m, snap.Checkpoint, nil
+}
+
+// Snapshotter wraps the checkpoint of a MemoryDB and takes a
+// snapshot whenever the checkpoint moves and the interval has
+// passed since the last one. The checkpoint only moves once a
+// block has been written, so the snapshot never contains a
+// partially ingested block.
+type Snapshotter struct {
+	checkpoint.CheckpointHandler
+
+	mu       sync.Mutex
+	db       *MemoryDB
+	path     string
+	interval time.Duration
+	last     time.Time
+}
+
+func NewSnapshotter(db *MemoryDB, cp checkpoint.CheckpointHandler, path string, interval time.Duration) *Snapshotter {
+	return &Snapshotter{
+		CheckpointHandler: cp,
+		db:                db,
+		path:              path,
+		interval:          interval,
+		last:              time.Now(),
+	}
+}
+
+// Snapshot takes a snapshot at the current checkpoint.
+func (s *Snapshotter) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.db.Snapshot(s.path, s.Checkpoint()); err != nil {
+		return err
+	}
+
+	s.last = time.Now()
+	return nil
+}
+
+func (s *Snapshotter) SetCheckpoint(n uint64) error {
+	if err := s.CheckpointHandler.SetCheckpoint(n); err != nil {
+		return err
+	}
+	return s.tick()
+}
+
+func (s *Snapshotter) SetCheckpointHash(n uint64, hash common.Hash) error {
+	if err := checkpoint.SetCheckpointHash(s.CheckpointHandler, n, hash); err != nil {
+		return err
+	}
+	return s.tick()
+}
+
+func (s *Snapshotter) Increase() error {
+	if err := s.CheckpointHandler.Increase(); err != nil {
+		return err
+	}
+	return s.tick()
+}
+
+func (s *Snapshotter) Decrease() error {
+	if err := s.CheckpointHandler.Decrease(); err != nil {
+		return err
+	}
+	return s.tick()
+}
+
+// tick takes a snapshot if it is due. The checkpoint has already
+// moved at this point, so a failed snapshot is not reported as a
+// checkpoint error, it is retried on the next move instead.
+func (s *Snapshotter) tick() error {
+	s.mu.Lock()
+	due := time.Since(s.last) >= s.interval
+	s.mu.Unlock()
+
+	if due {
+		if err := s.Snapshot(); err != nil {
+			log.Printf("memdb snapshot failed: %v", err)
+		}
+	}
+	return nil
+}
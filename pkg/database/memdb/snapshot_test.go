@@ -0,0 +1,62 @@
+package memdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dbadoy/grinder/pkg/checkpoint"
+)
+
+func TestSnapshot(t *testing.T) {
+	var (
+		db   = New()
+		path = filepath.Join(t.TempDir(), "memdb.snapshot")
+		cp   = checkpoint.New(checkpoint.DefaultBasePath, "snapshot")
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	if err := db.Insert([]byte("key"), &testData{[]string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Insert([]byte("key"), &otherData{[]string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every move of the checkpoint takes a snapshot.
+	s := NewSnapshotter(db, cp, path, 0)
+	if err := s.SetCheckpoint(41); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Increase(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, n, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 42 {
+		t.Fatalf("TestSnapshot, want: 42 got: %d", n)
+	}
+
+	if loaded.Size() != 2 {
+		t.Fatalf("TestSnapshot, want: 2 got: %d", loaded.Size())
+	}
+
+	docs, err := loaded.Search("test", "Terms", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("TestSnapshot, want: 1 got: %d", len(docs))
+	}
+
+	if _, _, err := Load(filepath.Join(t.TempDir(), "none")); !os.IsNotExist(err) {
+		t.Fatalf("TestSnapshot, want: not exist got: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package pebble
+
+import (
+	"github.com/cockroachdb/pebble"
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+var _ database.Batch = (*Batch)(nil)
+
+// Batch queues writes and commits them atomically with a single
+// Pebble batch. If any operation fails (e.g. an insert of an
+// existing key), nothing is committed.
+type Batch struct {
+	d   *DB
+	ops []*database.Operation
+}
+
+func (d *DB) NewBatch() database.Batch {
+	return &Batch{d: d}
+}
+
+func (b *Batch) Insert(key []byte, data database.Data) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpInsert, Index: data.Index(), Key: key, Data: data})
+}
+
+func (b *Batch) Put(key []byte, data database.Data) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpPut, Index: data.Index(), Key: key, Data: data})
+}
+
+func (b *Batch) Delete(index string, key []byte) {
+	b.ops = append(b.ops, &database.Operation{Kind: database.OpDelete, Index: index, Key: key})
+}
+
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+func (b *Batch) Write() ([]error, error) {
+	b.d.mu.Lock()
+	defer b.d.mu.Unlock()
+
+	batch := b.d.db.NewIndexedBatch()
+	defer batch.Close()
+
+	var (
+		results = make([]error, len(b.ops))
+		failed  = false
+	)
+	for i, op := range b.ops {
+		if results[i] = apply(batch, op); results[i] != nil {
+			failed = true
+		}
+	}
+
+	b.ops = nil
+
+	// The batch is closed without being committed.
+	if failed {
+		return aborted(results), nil
+	}
+
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// aborted sets the results of the operations that succeeded to
+// database.ErrAborted.
+func aborted(results []error) []error {
+	for i, res := range results {
+		if res == nil {
+			results[i] = database.ErrAborted
+		}
+	}
+	return results
+}
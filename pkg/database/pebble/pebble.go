@@ -0,0 +1,345 @@
+package pebble
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+var (
+	_ database.Database = (*DB)(nil)
+
+	// Documents are stored under 'd<index>\x00<key>', and every term
+	// of a list field under 'p<index>\x00<field>\x00<term>\x00<key>'.
+	// The posting entries have no value, iterating a term prefix
+	// gives the keys of the documents containing it.
+	documentPrefix = []byte("d")
+	postingPrefix  = []byte("p")
+
+	separator = []byte{0}
+)
+
+// DB is an embedded database.Database backed by Pebble. It keeps
+// an inverted index of the list fields of the stored documents, so
+// that Search is answered locally.
+type DB struct {
+	// mu serializes the writes, an insert has to read the key
+	// before writing it.
+	mu sync.Mutex
+	db *pebble.DB
+}
+
+func New(path string) (*DB, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// database.Database
+func (d *DB) HealthCheck() error {
+	_, closer, err := d.db.Get([]byte{})
+	if err == nil {
+		closer.Close()
+		return nil
+	}
+	if err == pebble.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (d *DB) Insert(key []byte, data database.Data) error {
+	return d.write(&database.Operation{Kind: database.OpInsert, Index: data.Index(), Key: key, Data: data})
+}
+
+func (d *DB) Put(key []byte, data database.Data) error {
+	return d.write(&database.Operation{Kind: database.OpPut, Index: data.Index(), Key: key, Data: data})
+}
+
+func (d *DB) Delete(index string, key []byte) error {
+	return d.write(&database.Operation{Kind: database.OpDelete, Index: index, Key: key})
+}
+
+func (d *DB) Exist(index string, key []byte) (bool, error) {
+	_, closer, err := d.db.Get(documentKey(index, key))
+	if err == pebble.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	closer.Close()
+	return true, nil
+}
+
+func (d *DB) Get(key []byte, data database.Data) error {
+	value, err := get(d.db, documentKey(data.Index(), key))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, data)
+}
+
+func (d *DB) MultiGet(index string, keys [][]byte) ([]*database.Document, error) {
+	docs := make([]*database.Document, 0, len(keys))
+	for _, key := range keys {
+		value, err := get(d.db, documentKey(index, key))
+		if err == database.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, &database.Document{Key: key, Value: value})
+	}
+
+	return docs, nil
+}
+
+func (d *DB) Scan(index string, offset int, limit int) ([]*database.Document, error) {
+	if offset < 0 || limit < 0 {
+		return nil, database.ErrInvalidRange
+	}
+
+	prefix := documentKey(index, nil)
+
+	iter := d.db.NewIter(prefixOptions(prefix))
+	defer iter.Close()
+
+	docs := make([]*database.Document, 0)
+	for iter.First(); iter.Valid() && len(docs) < limit; iter.Next() {
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		docs = append(docs, &database.Document{
+			Key:   clone(iter.Key()[len(prefix):]),
+			Value: clone(iter.Value()),
+		})
+	}
+
+	return docs, iter.Error()
+}
+
+func (d *DB) Count(index string) (int, error) {
+	iter := d.db.NewIter(prefixOptions(documentKey(index, nil)))
+	defer iter.Close()
+
+	var n int
+	for iter.First(); iter.Valid(); iter.Next() {
+		n++
+	}
+
+	return n, iter.Error()
+}
+
+func (d *DB) Search(index string, field string, terms []string) ([]*database.Document, error) {
+	docs := make([]*database.Document, 0)
+	if len(terms) == 0 {
+		return docs, nil
+	}
+
+	// Walk the posting list of the first term, and look up the
+	// rest of the terms for each key.
+	prefix := postingKey(index, field, terms[0], nil)
+
+	iter := d.db.NewIter(prefixOptions(prefix))
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := clone(iter.Key()[len(prefix):])
+
+		match := true
+		for _, term := range terms[1:] {
+			_, err := get(d.db, postingKey(index, field, term, key))
+			if err == database.ErrNotFound {
+				match = false
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !match {
+			continue
+		}
+
+		value, err := get(d.db, documentKey(index, key))
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, &database.Document{Key: key, Value: value})
+	}
+
+	return docs, iter.Error()
+}
+
+// write applies a single operation.
+func (d *DB) write(op *database.Operation) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	batch := d.db.NewIndexedBatch()
+	defer batch.Close()
+
+	if err := apply(batch, op); err != nil {
+		return err
+	}
+
+	return batch.Commit(pebble.Sync)
+}
+
+// apply adds the operation and the changes of the inverted index
+// to the batch. Reads go through the batch, so the operations
+// queued before are visible.
+func apply(batch *pebble.Batch, op *database.Operation) error {
+	dk := documentKey(op.Index, op.Key)
+
+	prev, err := get(batch, dk)
+	switch {
+	case err == database.ErrNotFound:
+		prev = nil
+	case err != nil:
+		return err
+	}
+
+	var value []byte
+
+	switch op.Kind {
+	case database.OpInsert:
+		if prev != nil {
+			return database.ErrAlreadyExist
+		}
+	case database.OpDelete:
+		if prev == nil {
+			return database.ErrNotFound
+		}
+	}
+
+	if op.Kind != database.OpDelete {
+		if value, err = json.Marshal(op.Data); err != nil {
+			return err
+		}
+	}
+
+	if prev != nil {
+		if err := index(batch, op.Index, op.Key, prev, batch.Delete); err != nil {
+			return err
+		}
+	}
+
+	if op.Kind == database.OpDelete {
+		return batch.Delete(dk, nil)
+	}
+
+	if err := batch.Set(dk, value, nil); err != nil {
+		return err
+	}
+
+	return index(batch, op.Index, op.Key, value, func(key []byte, opts *pebble.WriteOptions) error {
+		return batch.Set(key, nil, opts)
+	})
+}
+
+// index calls fn with the posting key of every term in the list
+// and string fields of the encoded document.
+func index(batch *pebble.Batch, idx string, key []byte, value []byte, fn func([]byte, *pebble.WriteOptions) error) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return err
+	}
+
+	for field, raw := range fields {
+		terms, ok := fieldTerms(raw)
+		if !ok {
+			continue
+		}
+
+		for _, term := range terms {
+			if err := fn(postingKey(idx, field, term, key), nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type reader interface {
+	Get(key []byte) ([]byte, io.Closer, error)
+}
+
+// get returns a copy of the value, pebble only guarantees the
+// returned slice until the closer is closed.
+func get(r reader, key []byte) ([]byte, error) {
+	value, closer, err := r.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	return clone(value), nil
+}
+
+func documentKey(index string, key []byte) []byte {
+	return join(documentPrefix, []byte(index), separator, key)
+}
+
+func postingKey(index string, field string, term string, key []byte) []byte {
+	return join(postingPrefix, []byte(index), separator, []byte(field), separator, []byte(term), separator, key)
+}
+
+func prefixOptions(prefix []byte) *pebble.IterOptions {
+	return &pebble.IterOptions{LowerBound: prefix, UpperBound: upperBound(prefix)}
+}
+
+// upperBound returns the smallest key greater than every key with
+// the given prefix.
+func upperBound(prefix []byte) []byte {
+	end := clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func clone(b []byte) []byte {
+	return append([]byte{}, b...)
+}
+
+// fieldTerms returns the terms of a list field, or the value of a
+// string field as its only term.
+func fieldTerms(raw json.RawMessage) ([]string, bool) {
+	var terms []string
+	if err := json.Unmarshal(raw, &terms); err == nil {
+		return terms, true
+	}
+
+	var term string
+	if err := json.Unmarshal(raw, &term); err == nil && term != "" {
+		return []string{term}, true
+	}
+	return nil, false
+}
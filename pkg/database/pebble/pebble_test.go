@@ -0,0 +1,203 @@
+package pebble
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dbadoy/grinder/pkg/database"
+)
+
+type testData struct {
+	Terms []string
+}
+
+func (testData) Index() string { return "test" }
+
+type namedData struct {
+	Name string
+}
+
+func (namedData) Index() string { return "named" }
+
+func newTestDB(t *testing.T) *DB {
+	db, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestWrite(t *testing.T) {
+	db := newTestDB(t)
+
+	key := []byte("0x01")
+
+	if err := db.Insert(key, &testData{[]string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Insert(key, &testData{[]string{"c"}}); err != database.ErrAlreadyExist {
+		t.Fatalf("TestWrite, want: %v got: %v", database.ErrAlreadyExist, err)
+	}
+
+	if ok, err := db.Exist("test", key); err != nil || !ok {
+		t.Fatalf("TestWrite, want: exist got: %v (%v)", ok, err)
+	}
+
+	// Overwriting must replace the postings of the previous value.
+	if err := db.Put(key, &testData{[]string{"c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"a"}); len(docs) != 0 {
+		t.Fatalf("TestWrite, want: 0 got: %d", len(docs))
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"c"}); len(docs) != 1 {
+		t.Fatalf("TestWrite, want: 1 got: %d", len(docs))
+	}
+
+	if err := db.Delete("test", key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Delete("test", key); err != database.ErrNotFound {
+		t.Fatalf("TestWrite, want: %v got: %v", database.ErrNotFound, err)
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"c"}); len(docs) != 0 {
+		t.Fatalf("TestWrite, want: 0 got: %d", len(docs))
+	}
+}
+
+func TestRead(t *testing.T) {
+	db := newTestDB(t)
+
+	n := 10
+	for i := 0; i < n; i++ {
+		terms := []string{"all", fmt.Sprintf("%d", i%2)}
+		if err := db.Insert([]byte(fmt.Sprintf("key-%d", i)), &testData{terms}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := new(testData)
+	if err := db.Get([]byte("key-3"), data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Terms[1] != "1" {
+		t.Fatalf("TestRead, want: 1 got: %s", data.Terms[1])
+	}
+
+	docs, err := db.MultiGet("test", [][]byte{[]byte("key-1"), []byte("key-2"), []byte("key-none")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("TestRead, want: 2 got: %d", len(docs))
+	}
+
+	if count, _ := db.Count("test"); count != n {
+		t.Fatalf("TestRead, want: %d got: %d", n, count)
+	}
+
+	docs, err = db.Scan("test", 8, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || string(docs[0].Key) != "key-8" {
+		t.Fatalf("TestRead, want: [key-8 key-9] got: %d", len(docs))
+	}
+
+	if _, err := db.Scan("test", -1, 5); err != database.ErrInvalidRange {
+		t.Fatalf("TestRead, want: %v got: %v", database.ErrInvalidRange, err)
+	}
+
+	docs, err = db.Search("test", "Terms", []string{"all", "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != n/2 {
+		t.Fatalf("TestRead, want: %d got: %d", n/2, len(docs))
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.Insert([]byte("exist"), &testData{[]string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := db.NewBatch()
+	batch.Insert([]byte("new"), &testData{[]string{"a"}})
+	batch.Insert([]byte("new"), &testData{[]string{"b"}})
+	batch.Insert([]byte("exist"), &testData{[]string{"b"}})
+	batch.Delete("test", []byte("none"))
+
+	results, err := batch.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []error{database.ErrAborted, database.ErrAlreadyExist, database.ErrAlreadyExist, database.ErrNotFound}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Fatalf("TestBatch, item %d want: %v got: %v", i, want[i], results[i])
+		}
+	}
+
+	// Nothing is committed if any operation fails.
+	if ok, _ := db.Exist("test", []byte("new")); ok {
+		t.Fatalf("TestBatch, want: false got: %v", ok)
+	}
+
+	batch.Insert([]byte("new"), &testData{[]string{"a"}})
+	batch.Delete("test", []byte("exist"))
+
+	results, err = batch.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, res := range results {
+		if res != nil {
+			t.Fatalf("TestBatch, item %d want: <nil> got: %v", i, res)
+		}
+	}
+
+	if docs, _ := db.Search("test", "Terms", []string{"a"}); len(docs) != 1 {
+		t.Fatalf("TestBatch, want: 1 got: %d", len(docs))
+	}
+	if ok, _ := db.Exist("test", []byte("exist")); ok {
+		t.Fatalf("TestBatch, want: false got: %v", ok)
+	}
+}
+
+func TestSearchString(t *testing.T) {
+	db := newTestDB(t)
+
+	for i, name := range []string{"a", "b", "a", ""} {
+		if err := db.Insert([]byte(fmt.Sprintf("key-%d", i)), &namedData{name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs, err := db.Search("named", "Name", []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("TestSearchString, want: 2 got: %d", len(docs))
+	}
+
+	if err := db.Put([]byte("key-0"), &namedData{"b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if docs, _ := db.Search("named", "Name", []string{"a"}); len(docs) != 1 {
+		t.Fatalf("TestSearchString, want: 1 got: %d", len(docs))
+	}
+}
@@ -0,0 +1,190 @@
+// Package disasm disassembles EVM bytecode into instructions and
+// splits them into basic blocks connected by the static jumps.
+package disasm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Instruction is a single decoded opcode.
+type Instruction struct {
+	PC int
+	Op vm.OpCode
+
+	// Arg is the PUSH data. If the code ends in the middle of it,
+	// the missing bytes are zero (as the EVM reads them) and
+	// Truncated is set.
+	Arg       []byte
+	Truncated bool
+}
+
+// Value returns the PUSH data as an integer.
+func (ins *Instruction) Value() *big.Int {
+	return new(big.Int).SetBytes(ins.Arg)
+}
+
+// Block is a basic block, a run of instructions that is only
+// entered at the first one and only left after the last one.
+type Block struct {
+	// Start and End are the range of the block in
+	// Program.Instructions, End is exclusive.
+	Start int
+	End   int
+
+	// Succs are the indices of the blocks control can continue
+	// to, through a fall-through or a jump whose target is pushed
+	// right before it. Jumps to computed targets (e.g. returns
+	// from internal functions) are not resolved.
+	Succs []int
+}
+
+// Program is disassembled code.
+type Program struct {
+	Instructions []*Instruction
+	Blocks       []*Block
+
+	// JumpDests maps the program counter of every JUMPDEST to its
+	// index in Instructions.
+	JumpDests map[int]int
+
+	// blockAt maps the first instruction of a block to the block.
+	blockAt map[int]int
+}
+
+// Disassemble decodes the code into instructions. It never fails,
+// bytes that are not valid opcodes are kept as they are.
+func Disassemble(code []byte) []*Instruction {
+	instructions := make([]*Instruction, 0, len(code))
+
+	for pc := 0; pc < len(code); pc++ {
+		ins := &Instruction{PC: pc, Op: vm.OpCode(code[pc])}
+
+		if ins.Op.IsPush() {
+			size := int(ins.Op-vm.PUSH1) + 1
+			ins.Arg = make([]byte, size)
+
+			n := copy(ins.Arg, code[pc+1:])
+			ins.Truncated = n < size
+			pc += size
+		}
+
+		instructions = append(instructions, ins)
+	}
+
+	return instructions
+}
+
+// New disassembles the code and builds its control-flow graph.
+func New(code []byte) *Program {
+	p := &Program{
+		Instructions: Disassemble(code),
+		JumpDests:    make(map[int]int),
+		blockAt:      make(map[int]int),
+	}
+
+	for i, ins := range p.Instructions {
+		if ins.Op == vm.JUMPDEST {
+			p.JumpDests[ins.PC] = i
+		}
+	}
+
+	p.split()
+	p.link()
+
+	return p
+}
+
+// split cuts the instructions into blocks. A block starts at a
+// JUMPDEST and ends after a jump or a halting instruction.
+func (p *Program) split() {
+	start := 0
+	for i, ins := range p.Instructions {
+		if ins.Op == vm.JUMPDEST && i > start {
+			p.addBlock(start, i)
+			start = i
+		}
+
+		if ins.Op == vm.JUMP || ins.Op == vm.JUMPI || IsTerminal(ins.Op) {
+			p.addBlock(start, i+1)
+			start = i + 1
+		}
+	}
+
+	if start < len(p.Instructions) {
+		p.addBlock(start, len(p.Instructions))
+	}
+}
+
+func (p *Program) addBlock(start, end int) {
+	p.blockAt[start] = len(p.Blocks)
+	p.Blocks = append(p.Blocks, &Block{Start: start, End: end})
+}
+
+func (p *Program) link() {
+	for i, b := range p.Blocks {
+		last := p.Instructions[b.End-1]
+
+		if last.Op == vm.JUMP || last.Op == vm.JUMPI {
+			if dest, ok := p.staticTarget(b); ok {
+				b.Succs = append(b.Succs, dest)
+			}
+		}
+
+		if last.Op != vm.JUMP && !IsTerminal(last.Op) && i+1 < len(p.Blocks) {
+			b.Succs = append(b.Succs, i+1)
+		}
+	}
+}
+
+// staticTarget returns the block the jump at the end of b goes to,
+// if the target is pushed right before the jump.
+func (p *Program) staticTarget(b *Block) (int, bool) {
+	if b.End-b.Start < 2 {
+		return 0, false
+	}
+
+	push := p.Instructions[b.End-2]
+	if !push.Op.IsPush() {
+		return 0, false
+	}
+
+	return p.BlockAt(push.Value())
+}
+
+// BlockAt returns the index of the block that begins with the
+// JUMPDEST at the given program counter.
+func (p *Program) BlockAt(pc *big.Int) (int, bool) {
+	if !pc.IsInt64() {
+		return 0, false
+	}
+
+	i, ok := p.JumpDests[int(pc.Int64())]
+	if !ok {
+		return 0, false
+	}
+
+	block, ok := p.blockAt[i]
+	return block, ok
+}
+
+// BlockOf returns the index of the block that contains the i-th
+// instruction.
+func (p *Program) BlockOf(i int) int {
+	lo, hi := 0, len(p.Blocks)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if p.Blocks[mid].End <= i {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// Code returns the instructions of the block.
+func (p *Program) Code(b *Block) []*Instruction {
+	return p.Instructions[b.Start:b.End]
+}
@@ -0,0 +1,94 @@
+package disasm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func TestDisassemble(t *testing.T) {
+	// PUSH1 0x80 ADD PUSH4 0xaabb (truncated)
+	instructions := Disassemble(common.Hex2Bytes("60800163aabb"))
+	if len(instructions) != 3 {
+		t.Fatalf("TestDisassemble, want: 3 got: %d", len(instructions))
+	}
+
+	last := instructions[2]
+	if last.PC != 3 || last.Op != vm.PUSH4 || !last.Truncated {
+		t.Fatalf("TestDisassemble, want: (3 PUSH4 true) got: (%d %v %v)", last.PC, last.Op, last.Truncated)
+	}
+	if common.Bytes2Hex(last.Arg) != "aabb0000" {
+		t.Fatalf("TestDisassemble, want: aabb0000 got: %x", last.Arg)
+	}
+
+	if instructions[0].Truncated || instructions[0].Value().Uint64() != 0x80 {
+		t.Fatalf("TestDisassemble, want: 0x80 got: %x", instructions[0].Arg)
+	}
+
+	// Every truncated push size must decode without panicking.
+	for op := vm.PUSH1; op <= vm.PUSH32; op++ {
+		Disassemble([]byte{byte(op)})
+	}
+}
+
+func TestControlFlow(t *testing.T) {
+	// 0x00: PUSH1 0x00 CALLDATALOAD PUSH1 0x0a JUMPI
+	// 0x06: PUSH1 0x0c JUMP
+	// 0x09: INVALID
+	// 0x0a: JUMPDEST STOP
+	// 0x0c: JUMPDEST CALLER JUMP
+	p := New(common.Hex2Bytes("600035600a" + "57" + "600c56" + "fe" + "5b00" + "5b3356"))
+
+	if len(p.Blocks) != 5 {
+		t.Fatalf("TestControlFlow, want: 5 got: %d", len(p.Blocks))
+	}
+
+	want := [][]int{
+		{3, 1}, // jump to 0x0a, fall through to 0x06
+		{4},    // jump to 0x0c
+		nil,    // INVALID
+		nil,    // STOP
+		nil,    // computed jump
+	}
+	for i, b := range p.Blocks {
+		if len(b.Succs) != len(want[i]) {
+			t.Fatalf("TestControlFlow, block %d want: %v got: %v", i, want[i], b.Succs)
+		}
+		for j := range want[i] {
+			if b.Succs[j] != want[i][j] {
+				t.Fatalf("TestControlFlow, block %d want: %v got: %v", i, want[i], b.Succs)
+			}
+		}
+	}
+
+	if p.BlockOf(5) != 1 || p.BlockOf(7) != 3 {
+		t.Fatalf("TestControlFlow, want: (1 3) got: (%d %d)", p.BlockOf(5), p.BlockOf(7))
+	}
+}
+
+func TestStackEffect(t *testing.T) {
+	tests := []struct {
+		op        vm.OpCode
+		pop, push int
+	}{
+		{vm.ADD, 2, 1},
+		{vm.PUSH32, 0, 1},
+		{vm.DUP3, 3, 4},
+		{vm.SWAP2, 3, 3},
+		{vm.LOG2, 4, 0},
+		{vm.CALL, 7, 1},
+		{vm.STATICCALL, 6, 1},
+	}
+
+	for _, test := range tests {
+		pop, push, ok := StackEffect(test.op)
+		if !ok || pop != test.pop || push != test.push {
+			t.Fatalf("TestStackEffect, %v want: (%d %d) got: (%d %d)", test.op, test.pop, test.push, pop, push)
+		}
+	}
+
+	if _, _, ok := StackEffect(vm.OpCode(0x0c)); ok {
+		t.Fatal("TestStackEffect, want: undefined got: defined")
+	}
+}
@@ -2,12 +2,15 @@ package ethclient
 
 import (
 	"context"
+	"fmt"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func DefaultHeartbeat(ctx context.Context, endpoint string) error {
@@ -35,6 +38,25 @@ type Client interface {
 	GetTransactionLogs(ctx context.Context, txHash common.Hash) ([]*types.Log, error)
 	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
 	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+
+	// TraceBlockByNumber returns the call frames of the
+	// transactions in the block, in the same order.
+	TraceBlockByNumber(ctx context.Context, blockNumber uint64) ([]*CallFrame, error)
+}
+
+// CallFrame is a call frame reported by the callTracer. For CREATE
+// and CREATE2 frames, To is the address of the new contract.
+type CallFrame struct {
+	Type  string         `json:"type"`
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Input hexutil.Bytes  `json:"input"`
+
+	// Error is set if the frame reverted, and the state changes
+	// of the frame and its calls were discarded.
+	Error string       `json:"error,omitempty"`
+	Calls []*CallFrame `json:"calls,omitempty"`
 }
 
 var _ Client = (*client)(nil)
@@ -42,14 +64,18 @@ var _ Client = (*client)(nil)
 type client struct {
 	endpoint string
 	eth      *ethclient.Client
+
+	// rpc serves the methods that ethclient.Client does not
+	// expose (e.g. debug_traceBlockByNumber).
+	rpc *rpc.Client
 }
 
 func New(endpoint string) (Client, error) {
-	eth, err := ethclient.Dial(endpoint)
+	rpc, err := rpc.Dial(endpoint)
 	if err != nil {
 		return nil, err
 	}
-	return &client{endpoint, eth}, nil
+	return &client{endpoint, ethclient.NewClient(rpc), rpc}, nil
 }
 
 func (c *client) Endpoint() string {
@@ -145,3 +171,30 @@ func (c *client) CodeAt(ctx context.Context, account common.Address, blockNumber
 func (c *client) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
 	return c.eth.StorageAt(ctx, account, key, blockNumber)
 }
+
+func (c *client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.eth.CallContract(ctx, msg, blockNumber)
+}
+
+// TraceBlockByNumber uses debug_traceBlockByNumber, which must be
+// enabled on the node.
+func (c *client) TraceBlockByNumber(ctx context.Context, blockNumber uint64) ([]*CallFrame, error) {
+	var res []struct {
+		Result *CallFrame `json:"result"`
+		Error  string     `json:"error"`
+	}
+
+	err := c.rpc.CallContext(ctx, &res, "debug_traceBlockByNumber", hexutil.EncodeUint64(blockNumber), map[string]string{"tracer": "callTracer"})
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]*CallFrame, 0, len(res))
+	for _, r := range res {
+		if r.Error != "" {
+			return nil, fmt.Errorf("trace failed: %s", r.Error)
+		}
+		frames = append(frames, r.Result)
+	}
+	return frames, nil
+}
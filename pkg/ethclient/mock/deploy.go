@@ -14,43 +14,58 @@ import (
 // It is describing the overall steps of 'sending a transaction
 // - creating a block - getting a receipt' process well.
 func DeployContract(m *Mock, bytecode []byte) (common.Address, error) {
-	nonce, err := m.c.NonceAt(context.Background(), m.addr, nil)
+	receipt, err := sendTransaction(m, nil, bytecode)
 	if err != nil {
 		return common.Address{}, err
 	}
 
+	return receipt.ContractAddress, nil
+}
+
+// CallContract sends a transaction that calls the contract with the
+// given calldata, and mines it in a new block.
+func CallContract(m *Mock, to common.Address, data []byte) (*types.Receipt, error) {
+	return sendTransaction(m, &to, data)
+}
+
+func sendTransaction(m *Mock, to *common.Address, data []byte) (*types.Receipt, error) {
+	nonce, err := m.c.NonceAt(context.Background(), m.addr, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	gasPrice, err := m.c.SuggestGasPrice(context.Background())
 	if err != nil {
-		return common.Address{}, err
+		return nil, err
 	}
 
 	tx := types.NewTx(&types.LegacyTx{
 		Nonce:    nonce,
-		To:       nil,
+		To:       to,
 		Gas:      m.c.Blockchain().GasLimit(),
 		GasPrice: gasPrice,
-		Data:     bytecode,
+		Data:     data,
 	})
 
 	signed, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), m.priv)
 	if err != nil {
-		return common.Address{}, err
+		return nil, err
 	}
 
 	if err := m.c.SendTransaction(context.Background(), signed); err != nil {
-		return common.Address{}, err
+		return nil, err
 	}
 
 	m.c.Commit()
 
 	receipt, err := m.c.TransactionReceipt(context.Background(), signed.Hash())
 	if err != nil {
-		return common.Address{}, err
+		return nil, err
 	}
 
 	if receipt.Status == 0 {
-		return common.Address{}, errors.New("transaction failed")
+		return nil, errors.New("transaction failed")
 	}
 
-	return receipt.ContractAddress, nil
+	return receipt, nil
 }
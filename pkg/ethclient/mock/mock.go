@@ -31,6 +31,12 @@ var (
 var (
 	PrecompiledContractEIP1822 = "0x0000000000000000000000000000000000000001"
 	PrecompiledContractEIP1967 = "0x0000000000000000000000000000000000000002"
+
+	// PrecompiledContractBeacon is a beacon proxy, its beacon
+	// returns 0x00000000000000000000000000000000000000b3 from
+	// implementation().
+	PrecompiledContractBeacon = "0x0000000000000000000000000000000000000003"
+	precompiledBeacon         = "0x00000000000000000000000000000000000000bc"
 )
 
 // Mock is an alternative client for writing test scripts for
@@ -88,6 +94,23 @@ func New(hexPriv string) (*Mock, error) {
 				common.HexToHash(params.ImplementationAddressSlotEIP1967): common.HexToHash("0x00000000000000000000000000000000000000b2"),
 			},
 		},
+		common.HexToAddress(PrecompiledContractBeacon): {
+			Code:    []byte{1, 9, 6, 7},
+			Balance: new(big.Int),
+			Storage: map[common.Hash]common.Hash{
+				common.HexToHash(params.BeaconAddressSlotEIP1967): common.HexToHash(precompiledBeacon),
+			},
+		},
+		// PUSH20 impl PUSH1 0x00 MSTORE PUSH1 0x20 PUSH1 0x00 RETURN
+		common.HexToAddress(precompiledBeacon): {
+			Code:    common.FromHex("0x7300000000000000000000000000000000000000b360005260206000f3"),
+			Balance: new(big.Int),
+		},
+
+		// Implementation contracts of the templates (STOP)
+		common.HexToAddress("0xa1"): {Code: []byte{0}, Balance: new(big.Int)},
+		common.HexToAddress("0xb2"): {Code: []byte{0}, Balance: new(big.Int)},
+		common.HexToAddress("0xb3"): {Code: []byte{0}, Balance: new(big.Int)},
 	}
 
 	blockGasLimit := uint64(15000000)
@@ -195,3 +218,7 @@ func (m *Mock) CodeAt(ctx context.Context, account common.Address, blockNumber *
 func (m *Mock) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
 	return m.c.StorageAt(ctx, account, key, blockNumber)
 }
+
+func (m *Mock) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return m.c.CallContract(ctx, msg, blockNumber)
+}
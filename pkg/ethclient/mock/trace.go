@@ -0,0 +1,104 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/dbadoy/grinder/pkg/ethclient"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// TraceBlockByNumber re-executes the transactions of the block on
+// the state of its parent, and records the call frames the way
+// the callTracer does.
+func (m *Mock) TraceBlockByNumber(ctx context.Context, blockNumber uint64) ([]*ethclient.CallFrame, error) {
+	bc := m.c.Blockchain()
+
+	block := bc.GetBlockByNumber(blockNumber)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNumber)
+	}
+
+	parent := bc.GetBlockByHash(block.ParentHash())
+	if parent == nil {
+		return nil, fmt.Errorf("parent of block #%d not found", blockNumber)
+	}
+
+	statedb, err := bc.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas uint64
+		frames  = make([]*ethclient.CallFrame, 0, len(block.Transactions()))
+	)
+
+	for i, tx := range block.Transactions() {
+		tracer := new(callTracer)
+
+		statedb.SetTxContext(tx.Hash(), i)
+		if _, err := core.ApplyTransaction(bc.Config(), bc, nil, gp, statedb, block.Header(), tx, &usedGas, vm.Config{Debug: true, Tracer: tracer}); err != nil {
+			return nil, err
+		}
+
+		frames = append(frames, tracer.root)
+	}
+
+	return frames, nil
+}
+
+// callTracer builds the tree of call frames of a transaction.
+type callTracer struct {
+	root  *ethclient.CallFrame
+	stack []*ethclient.CallFrame
+}
+
+var _ vm.EVMLogger = (*callTracer)(nil)
+
+func (t *callTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	typ := vm.CALL
+	if create {
+		typ = vm.CREATE
+	}
+
+	t.root = &ethclient.CallFrame{Type: typ.String(), From: from, To: to, Input: common.CopyBytes(input)}
+	t.stack = []*ethclient.CallFrame{t.root}
+}
+
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if err != nil {
+		t.root.Error = err.Error()
+	}
+}
+
+func (t *callTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	var (
+		frame  = &ethclient.CallFrame{Type: typ.String(), From: from, To: to, Input: common.CopyBytes(input)}
+		parent = t.stack[len(t.stack)-1]
+	)
+
+	parent.Calls = append(parent.Calls, frame)
+	t.stack = append(t.stack, frame)
+}
+
+func (t *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	frame := t.stack[len(t.stack)-1]
+	if err != nil {
+		frame.Error = err.Error()
+	}
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *callTracer) CaptureTxStart(gasLimit uint64) {}
+func (t *callTracer) CaptureTxEnd(restGas uint64)    {}
+
+func (t *callTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+func (t *callTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
@@ -0,0 +1,121 @@
+package grinder
+
+import (
+	"github.com/dbadoy/grinder/pkg/disasm"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// dispatcherSelector reports whether the instruction at i pushes a
+// function selector that the dispatcher compares against the
+// selector loaded from the calldata, and returns it.
+//
+// Solidity dispatches with
+//
+//	PUSH4 x; DUP2; EQ; PUSH2 dest; JUMPI  (>= 0.5)
+//	DUP1; PUSH4 x; EQ; PUSH2 dest; JUMPI  (< 0.5)
+//
+// and large contracts split the selectors with a binary search
+// that uses GT (or LT) in place of EQ, the pivot being one of the
+// selectors. A range check in a function body has the same shape,
+// so the pivot is only accepted if the jump leads to another
+// dispatcher branch. The optimizer strips the leading zero bytes
+// of a selector (e.g. PUSH3 0xfdd58e), so PUSH3 is accepted as
+// well. Shorter pushes are not, they are mostly enum and small
+// integer comparisons, and neither is 0xffffffff, the mask of the
+// selector.
+//
+// Vyper jumps over the function body when the selector does not
+// match, with
+//
+//	PUSH4 x; DUP2; XOR; PUSH2 dest; JUMPI                     (>= 0.3)
+//	PUSH4 x; PUSH1 0; MLOAD; EQ; ISZERO; PUSH2 dest; JUMPI    (< 0.3)
+//
+// where the older versions keep the selector in memory.
+func dispatcherSelector(program *disasm.Program, i int) (string, bool) {
+	code := program.Instructions
+	if !isSelectorPush(code[i].Op) || selector(code[i].Arg) == "ffffffff" {
+		return "", false
+	}
+
+	var (
+		// PUSH x; DUP2; EQ; PUSH dest; JUMPI
+		after = match(code, i+1, isDup12, isOp(vm.EQ), isJumpDestPush, isOp(vm.JUMPI))
+
+		// DUP1; PUSH x; EQ; PUSH dest; JUMPI
+		before = i > 0 && match(code, i-1, isOp(vm.DUP1)) && match(code, i+1, isOp(vm.EQ), isJumpDestPush, isOp(vm.JUMPI))
+
+		// PUSH x; DUP2; GT; PUSH dest; JUMPI, and the DUP1 form
+		pivot = (match(code, i+1, isDup12, isRange, isJumpDestPush, isOp(vm.JUMPI)) && isDispatcherBranch(program, code[i+3])) ||
+			(i > 0 && match(code, i-1, isOp(vm.DUP1)) && match(code, i+1, isRange, isJumpDestPush, isOp(vm.JUMPI)) && isDispatcherBranch(program, code[i+2]))
+
+		// PUSH x; DUP2; XOR; PUSH dest; JUMPI
+		vyper = match(code, i+1, isDup12, isOp(vm.XOR), isJumpDestPush, isOp(vm.JUMPI))
+
+		// PUSH x; PUSH1 0; MLOAD; EQ; ISZERO; PUSH dest; JUMPI
+		vyperLegacy = match(code, i+1, isOp(vm.PUSH1), isOp(vm.MLOAD), isOp(vm.EQ), isOp(vm.ISZERO), isJumpDestPush, isOp(vm.JUMPI))
+	)
+
+	if !after && !before && !pivot && !vyper && !vyperLegacy {
+		return "", false
+	}
+
+	return selector(code[i].Arg), true
+}
+
+// isDispatcherBranch reports whether the pushed jump destination
+// is a block that compares the selector, as the half of a binary
+// search does.
+func isDispatcherBranch(program *disasm.Program, dest *disasm.Instruction) bool {
+	b, ok := program.BlockAt(dest.Value())
+	if !ok {
+		return false
+	}
+
+	var (
+		code  = program.Instructions
+		block = program.Blocks[b]
+	)
+
+	for j := block.Start; j < block.End; j++ {
+		if !isSelectorPush(code[j].Op) {
+			continue
+		}
+
+		if match(code, j+1, isDup12, isCompare, isJumpDestPush, isOp(vm.JUMPI)) ||
+			(j > block.Start && match(code, j-1, isOp(vm.DUP1)) && match(code, j+1, isCompare, isJumpDestPush, isOp(vm.JUMPI))) {
+			return true
+		}
+	}
+	return false
+}
+
+// selector left-pads the pushed value to 4 bytes.
+func selector(value []byte) string {
+	return common.Bytes2Hex(common.LeftPadBytes(value, 4))
+}
+
+// match reports whether the instructions starting at i satisfy the
+// given predicates in order.
+func match(code []*disasm.Instruction, i int, preds ...func(vm.OpCode) bool) bool {
+	if i+len(preds) > len(code) {
+		return false
+	}
+
+	for j, pred := range preds {
+		if !pred(code[i+j].Op) {
+			return false
+		}
+	}
+	return true
+}
+
+func isOp(op vm.OpCode) func(vm.OpCode) bool {
+	return func(code vm.OpCode) bool { return code == op }
+}
+
+func isSelectorPush(op vm.OpCode) bool { return op == vm.PUSH3 || op == vm.PUSH4 }
+func isJumpDestPush(op vm.OpCode) bool { return op >= vm.PUSH1 && op <= vm.PUSH3 }
+func isDup12(op vm.OpCode) bool        { return op == vm.DUP1 || op == vm.DUP2 }
+func isRange(op vm.OpCode) bool        { return op == vm.GT || op == vm.LT }
+func isCompare(op vm.OpCode) bool      { return op == vm.EQ || isRange(op) }
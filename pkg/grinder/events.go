@@ -0,0 +1,210 @@
+package grinder
+
+import (
+	"math/big"
+
+	"github.com/dbadoy/grinder/pkg/disasm"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// maxFlowSteps bounds the number of instructions simulated while
+// following a single pushed value.
+const maxFlowSteps = 1024
+
+// slot is a symbolic stack item. Only the followed value and small
+// constants (which may be jump targets) are told apart, everything
+// else is unknown.
+type slot struct {
+	tracked bool
+	known   bool
+	value   uint64
+}
+
+// flowKey identifies a simulated instruction with the shape of the
+// stack it was reached with.
+type flowKey struct {
+	i       int
+	height  int
+	tracked uint64
+}
+
+type flowState struct {
+	i     int
+	stack []slot
+}
+
+func (s *flowState) pop() slot {
+	if len(s.stack) == 0 {
+		// The item was on the stack before the simulation began.
+		return slot{}
+	}
+	top := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	return top
+}
+
+func (s *flowState) push(v slot) { s.stack = append(s.stack, v) }
+
+// peek returns the n-th item from the top (0 is the top).
+func (s *flowState) peek(n int) slot {
+	if n >= len(s.stack) {
+		return slot{}
+	}
+	return s.stack[len(s.stack)-1-n]
+}
+
+func (s *flowState) swap(n int) {
+	if n >= len(s.stack) {
+		pad := make([]slot, n+1-len(s.stack))
+		s.stack = append(pad, s.stack...)
+	}
+	top := len(s.stack) - 1
+	s.stack[top], s.stack[top-n] = s.stack[top-n], s.stack[top]
+}
+
+// trackedMask returns the positions of the followed value among
+// the 64 items on top of the stack, bit 0 being the top.
+func (s *flowState) trackedMask() uint64 {
+	var mask uint64
+	for k := 0; k < 64 && k < len(s.stack); k++ {
+		if s.stack[len(s.stack)-1-k].tracked {
+			mask |= 1 << k
+		}
+	}
+	return mask
+}
+
+func (s *flowState) holdsTracked() bool {
+	for _, v := range s.stack {
+		if v.tracked {
+			return true
+		}
+	}
+	return false
+}
+
+// isEventTopic reports whether the value pushed by the start-th
+// instruction is used as a topic of a LOG1-LOG4 instruction.
+//
+// The value is followed through a symbolic simulation of the
+// stack: DUP and SWAP move it, and any other instruction that pops
+// it consumes it. Jumps to constant destinations are followed too,
+// since the compiler usually emits the LOG in the block the ABI
+// encoder returns to rather than the one that pushed the topic.
+//
+// An instruction is simulated again if it is reached with the
+// value at another position of the stack, e.g. as the offset of
+// the LOG on one path and as a topic on another.
+func isEventTopic(p *disasm.Program, start int) bool {
+	var (
+		code    = p.Instructions
+		work    = []*flowState{{i: start + 1, stack: []slot{{tracked: true}}}}
+		visited = make(map[flowKey]struct{})
+		steps   = 0
+	)
+
+	for len(work) > 0 {
+		s := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for s.i < len(code) && steps < maxFlowSteps {
+			key := flowKey{s.i, len(s.stack), s.trackedMask()}
+			if _, ok := visited[key]; ok {
+				break
+			}
+			visited[key] = struct{}{}
+			steps++
+
+			ins := code[s.i]
+			s.i++
+
+			op := ins.Op
+			switch {
+			case op.IsPush():
+				s.push(constant(ins.Value()))
+				continue
+
+			case op == vm.PUSH0:
+				s.push(slot{known: true})
+				continue
+
+			case op >= vm.DUP1 && op <= vm.DUP16:
+				s.push(s.peek(int(op - vm.DUP1)))
+				continue
+
+			case op >= vm.SWAP1 && op <= vm.SWAP16:
+				s.swap(int(op-vm.SWAP1) + 1)
+				continue
+
+			case op >= vm.LOG1 && op <= vm.LOG4:
+				// LOGn takes offset, size, topic0 ... topic(n-1).
+				for k := 2; k < int(op-vm.LOG0)+2; k++ {
+					if s.peek(k).tracked {
+						return true
+					}
+				}
+
+			case op == vm.JUMP:
+				dest, ok := jumpTarget(p, s.pop())
+				if !ok {
+					s.i = len(code)
+					continue
+				}
+				s.i = dest
+				continue
+
+			case op == vm.JUMPI:
+				dest, ok := jumpTarget(p, s.pop())
+				s.pop()
+				if ok {
+					work = append(work, &flowState{i: dest, stack: append([]slot(nil), s.stack...)})
+				}
+				continue
+
+			case disasm.IsTerminal(op):
+				s.i = len(code)
+				continue
+			}
+
+			pop, push, ok := disasm.StackEffect(op)
+			if !ok {
+				s.i = len(code)
+				continue
+			}
+			for k := 0; k < pop; k++ {
+				s.pop()
+			}
+			for k := 0; k < push; k++ {
+				s.push(slot{})
+			}
+
+			if !s.holdsTracked() {
+				s.i = len(code)
+			}
+		}
+	}
+
+	return false
+}
+
+// constant returns the slot for a pushed value. Only values that
+// fit in a uint64 are kept, larger ones can not be jump targets.
+func constant(v *big.Int) slot {
+	if !v.IsUint64() {
+		return slot{}
+	}
+	return slot{known: true, value: v.Uint64()}
+}
+
+// jumpTarget returns the instruction a jump to v goes to.
+func jumpTarget(p *disasm.Program, v slot) (int, bool) {
+	if !v.known {
+		return 0, false
+	}
+
+	block, ok := p.BlockAt(new(big.Int).SetUint64(v.value))
+	if !ok {
+		return 0, false
+	}
+	return p.Blocks[block].Start, true
+}
@@ -2,39 +2,143 @@ package grinder
 
 import (
 	"errors"
-	"strings"
 
+	"github.com/dbadoy/grinder/pkg/disasm"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/vm"
 )
 
+// ErrNotContract is returned for empty code, e.g. an EOA or a
+// self-destructed contract.
+var ErrNotContract = errors.New("must be contract")
+
 // Grinder extracts the MethodID and EventID from the bytecode
 // and returns them.
 //
 // The return values are just a 'candidates'.
 func Grinde(bytecode []byte) (methods []string, events []string, err error) {
-	hex := common.Bytes2Hex(bytecode)
-	if !strings.Contains(hex, "60806040") {
-		return nil, nil, errors.New("must be contract")
+	if len(bytecode) == 0 {
+		return nil, nil, ErrNotContract
 	}
 
-	for _, opcode := range getOpCodes(bytecode) {
-		switch opcode.code {
+	code, _ := SplitMetadata(bytecode)
+
+	for _, ins := range disasm.Disassemble(code) {
+		switch ins.Op {
 		case vm.PUSH4:
-			method := common.Bytes2Hex(opcode.value)
+			method := common.Bytes2Hex(ins.Arg)
 			if method == "ffffffff" {
 				continue
 			}
 
 			methods = append(methods, method)
 		case vm.PUSH32:
-			events = append(events, common.Bytes2Hex(opcode.value))
+			events = append(events, common.Bytes2Hex(ins.Arg))
 		}
 	}
 
 	return removeDuplicateString(methods), removeDuplicateString(events), nil
 }
 
+// Result is the outcome of Analyze.
+type Result struct {
+	// Methods are the selectors the function dispatcher compares
+	// the calldata against. These are high-confidence methods.
+	Methods []string
+
+	// Constants are the other 4-byte values in the code, such as
+	// masks, custom error selectors and ERC-165 interface IDs.
+	Constants []string
+
+	// Events are the 32-byte values used as a LOG topic.
+	Events []string
+
+	// Hashes are the other 32-byte values in the code, such as
+	// storage slots, EIP-712 type hashes and type(uint256).max.
+	Hashes []string
+
+	// Metadata is the decoded metadata trailer, nil if the code
+	// does not end with one.
+	Metadata *Metadata
+}
+
+// Analyze works like Grinde, but recognises the function
+// dispatcher and reports its selectors separately from the other
+// 4-byte constants. Likewise, only the 32-byte values that flow
+// into a LOG topic are reported as events.
+//
+// Both the Solidity and the Vyper dispatchers are recognised. Any
+// other non-empty code (e.g. Huff or hand-written contracts) is
+// accepted as well, its selectors are then left in Constants. The
+// metadata trailer is decoded and left out of the scan.
+func Analyze(bytecode []byte) (*Result, error) {
+	if len(bytecode) == 0 {
+		return nil, ErrNotContract
+	}
+
+	code, meta := SplitMetadata(bytecode)
+
+	var (
+		program = disasm.New(code)
+
+		methods   = make([]string, 0)
+		constants = make([]string, 0)
+		events    = make([]string, 0)
+		hashes    = make([]string, 0)
+	)
+
+	for i, ins := range program.Instructions {
+		if method, ok := dispatcherSelector(program, i); ok {
+			methods = append(methods, method)
+			continue
+		}
+
+		switch ins.Op {
+		case vm.PUSH4:
+			constant := common.Bytes2Hex(ins.Arg)
+			if constant == "ffffffff" {
+				continue
+			}
+
+			constants = append(constants, constant)
+		case vm.PUSH32:
+			if isEventTopic(program, i) {
+				events = append(events, common.Bytes2Hex(ins.Arg))
+				continue
+			}
+
+			hashes = append(hashes, common.Bytes2Hex(ins.Arg))
+		}
+	}
+
+	methods = removeDuplicateString(methods)
+	events = removeDuplicateString(events)
+
+	return &Result{
+		Methods:   methods,
+		Constants: removeDuplicateString(exclude(constants, methods)),
+		Events:    events,
+		Hashes:    removeDuplicateString(exclude(hashes, events)),
+		Metadata:  meta,
+	}, nil
+}
+
+// exclude returns the elements of arr that are not in set.
+func exclude(arr []string, set []string) []string {
+	keys := make(map[string]struct{}, len(set))
+	for _, s := range set {
+		keys[s] = struct{}{}
+	}
+
+	res := make([]string, 0, len(arr))
+	for _, s := range arr {
+		if _, ok := keys[s]; !ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
 func removeDuplicateString(arr []string) (res []string) {
 	keys := make(map[string]struct{})
 	for _, s := range arr {
@@ -1,8 +1,10 @@
 package grinder
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/dbadoy/grinder/pkg/disasm"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -20,17 +22,223 @@ func TestGrinde(t *testing.T) {
 		t.Fatal("incorrect result")
 	}
 
-	//
-	//
+	// Code without the Solidity prologue is accepted.
 	temp := "a22cb4650000000000000000000000001e0049783f008a0085193e00003d00cd54003c710000000000000000000000000000000000000000000000000000000000000001"
-	if _, _, err := Grinde(common.Hex2Bytes(temp)); err == nil {
-		t.Fatal("incorrect result, want: failed got: success")
+	if _, _, err := Grinde(common.Hex2Bytes(temp)); err != nil {
+		t.Fatalf("incorrect result, want: success got: %v", err)
+	}
+
+	if _, _, err := Grinde(nil); err != ErrNotContract {
+		t.Fatalf("incorrect result, want: %v got: %v", ErrNotContract, err)
 	}
 }
 
 func TestOpCode(t *testing.T) {
-	opcodes := getOpCodes(common.Hex2Bytes(erc20))
-	if len(opcodes) != 968 {
-		t.Fatalf("incorrect result, want: %d got: %d", 968, len(opcodes))
+	instructions := disasm.Disassemble(common.Hex2Bytes(erc20))
+	if len(instructions) != 968 {
+		t.Fatalf("incorrect result, want: %d got: %d", 968, len(instructions))
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	res, err := Analyze(common.Hex2Bytes(erc20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Methods) != 2 || len(res.Constants) != 0 || len(res.Events) != 1 || len(res.Hashes) != 4 {
+		t.Fatalf("incorrect result, want: (2 0 1 4) got: (%d %d %d %d)", len(res.Methods), len(res.Constants), len(res.Events), len(res.Hashes))
+	}
+
+	// OwnerSet(address,address)
+	if res.Events[0] != "342827c97908e5e2f71151c08502a66d44b6f758e3ac2f1de95f02eb95f0a735" {
+		t.Fatalf("incorrect event, got: %s", res.Events[0])
+	}
+
+	// PUSH1 0x80 PUSH1 0x40 MSTORE PUSH1 0x00 CALLDATALOAD PUSH1 0xe0 SHR
+	// DUP1 PUSH4 0xaabbccdd GT PUSH2 0x0032 JUMPI
+	// DUP1 PUSH3 0xfdd58e EQ PUSH2 0x0040 JUMPI
+	// PUSH4 0x11223344 DUP2 EQ PUSH2 0x0050 JUMPI
+	// PUSH4 0x01ffc9a7 POP STOP
+	// 0x32: JUMPDEST DUP1 PUSH4 0x55667788 EQ PUSH2 0x0040 JUMPI STOP
+	// PUSH4 0x12345678 DUP2 GT PUSH2 0x004b JUMPI STOP
+	// 0x4b: JUMPDEST STOP
+	// PUSH4 0xffffffff DUP2 EQ PUSH2 0x0040 JUMPI STOP
+	code := "6080604052600035" + "60e01c" +
+		"8063aabbccdd1161003257" +
+		"8062fdd58e1461004057" +
+		"63112233448114610050" + "57" +
+		"6301ffc9a75000" +
+		"5b" + "80635566778814610040" + "57" + "00" +
+		"631234567881116100" + "4b" + "57" + "00" +
+		"5b00" +
+		"63ffffffff8114610040" + "57" + "00"
+
+	res, err = Analyze(common.Hex2Bytes(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The range check of 0x12345678 jumps to a function body.
+	want := []string{"aabbccdd", "00fdd58e", "11223344", "55667788"}
+	if len(res.Methods) != len(want) {
+		t.Fatalf("incorrect methods, want: %v got: %v", want, res.Methods)
+	}
+	for i := range want {
+		if res.Methods[i] != want[i] {
+			t.Fatalf("incorrect methods, want: %v got: %v", want, res.Methods)
+		}
+	}
+
+	if len(res.Constants) != 2 || res.Constants[0] != "01ffc9a7" || res.Constants[1] != "12345678" {
+		t.Fatalf("incorrect constants, want: [01ffc9a7 12345678] got: %v", res.Constants)
+	}
+}
+
+func TestEventTopic(t *testing.T) {
+	var (
+		topic = strings.Repeat("11", 32)
+		hash  = strings.Repeat("ff", 32)
+	)
+
+	// PUSH1 0x80 PUSH1 0x40 MSTORE
+	// PUSH32 topic PUSH2 0x54 PUSH2 0x4f JUMP
+	// PUSH32 hash POP
+	// 0x4f: JUMPDEST PUSH1 0x20 SWAP1 JUMP
+	// 0x54: JUMPDEST PUSH1 0x00 LOG1 STOP
+	code := "6080604052" +
+		"7f" + topic + "610054" + "61004f" + "56" +
+		"7f" + hash + "50" +
+		"5b" + "6020" + "90" + "56" +
+		"5b" + "6000" + "a1" + "00"
+
+	res, err := Analyze(common.Hex2Bytes(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Events) != 1 || res.Events[0] != topic {
+		t.Fatalf("incorrect events, want: [%s] got: %v", topic, res.Events)
+	}
+
+	if len(res.Hashes) != 1 || res.Hashes[0] != hash {
+		t.Fatalf("incorrect hashes, want: [%s] got: %v", hash, res.Hashes)
+	}
+}
+
+func TestEventTopicPaths(t *testing.T) {
+	topic := strings.Repeat("33", 32)
+
+	// PUSH32 topic PUSH1 0x01 PUSH1 0x2e JUMPI
+	// PUSH1 0x00 PUSH1 0x00 SWAP2 PUSH1 0x36 JUMP
+	// 0x2e: JUMPDEST PUSH1 0x00 PUSH1 0x00 PUSH1 0x36 JUMP
+	// 0x36: JUMPDEST LOG1 STOP
+	//
+	// The LOG block is reached first with the topic as the offset,
+	// then with the same stack height and the topic as a topic.
+	code := "7f" + topic + "6001" + "602e" + "57" +
+		"6000" + "6000" + "91" + "6036" + "56" +
+		"5b" + "6000" + "6000" + "6036" + "56" +
+		"5b" + "a1" + "00"
+
+	res, err := Analyze(common.Hex2Bytes(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Events) != 1 || res.Events[0] != topic {
+		t.Fatalf("incorrect events, want: [%s] got: %v", topic, res.Events)
+	}
+}
+
+func TestAnalyzeVyper(t *testing.T) {
+	topic := strings.Repeat("22", 32)
+
+	// PUSH1 0x00 CALLDATALOAD PUSH1 0xe0 SHR
+	// PUSH4 0xaabbccdd DUP2 XOR PUSH2 0x0011 JUMPI
+	// 0x11: JUMPDEST PUSH4 0x11223344 PUSH1 0x00 MLOAD EQ ISZERO PUSH2 0x0047 JUMPI
+	// PUSH32 topic PUSH1 0x20 PUSH1 0x00 LOG1 STOP
+	// 0x47: JUMPDEST STOP
+	code := "600035" + "60e01c" +
+		"63aabbccdd" + "81" + "18" + "610011" + "57" +
+		"5b" + "6311223344" + "6000" + "51" + "14" + "15" + "610047" + "57" +
+		"7f" + topic + "6020" + "6000" + "a1" + "00" +
+		"5b00"
+
+	res, err := Analyze(common.Hex2Bytes(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"aabbccdd", "11223344"}
+	if len(res.Methods) != len(want) || res.Methods[0] != want[0] || res.Methods[1] != want[1] {
+		t.Fatalf("incorrect methods, want: %v got: %v", want, res.Methods)
+	}
+
+	if len(res.Events) != 1 || res.Events[0] != topic {
+		t.Fatalf("incorrect events, want: [%s] got: %v", topic, res.Events)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	code, meta := SplitMetadata(common.Hex2Bytes(erc20))
+	if meta == nil {
+		t.Fatal("incorrect result, want: metadata got: nil")
+	}
+	if len(code) != len(erc20)/2-53 {
+		t.Fatalf("incorrect code size, want: %d got: %d", len(erc20)/2-53, len(code))
+	}
+	if meta.Compiler != "solc 0.8.18" {
+		t.Fatalf("incorrect compiler, want: solc 0.8.18 got: %s", meta.Compiler)
+	}
+	if meta.Hash != "ipfs://QmeUzCWqcnhSuAu7Jzvdf6TWovACC8uFXq4XbaJJ9qKed4" {
+		t.Fatalf("incorrect hash, got: %s", meta.Hash)
+	}
+
+	// {"vyper": [0, 3, 7]}
+	_, meta = SplitMetadata(common.Hex2Bytes("6000" + "a165767970657283000307" + "000b"))
+	if meta == nil || meta.Compiler != "vyper 0.3.7" {
+		t.Fatalf("incorrect compiler, want: vyper 0.3.7 got: %v", meta)
+	}
+
+	for _, code := range []string{"", "00", "6000600052", "6000" + "a1" + "000b", "6000" + "a16361626301" + "0006"} {
+		if _, meta := SplitMetadata(common.Hex2Bytes(code)); meta != nil {
+			t.Fatalf("incorrect result, want: nil got: %v (%s)", meta, code)
+		}
+	}
+}
+
+func TestRuntimeCode(t *testing.T) {
+	creation := common.Hex2Bytes(erc20)
+
+	runtime, err := RuntimeCode(creation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runtime) != 0x4ca || !strings.HasSuffix(erc20, common.Bytes2Hex(runtime)) {
+		t.Fatalf("incorrect runtime size, want: %d got: %d", 0x4ca, len(runtime))
+	}
+
+	res, err := Analyze(runtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Methods) != 2 {
+		t.Fatalf("incorrect result, want: 2 got: %d", len(res.Methods))
+	}
+
+	// Vyper uses RETURNDATASIZE for zero.
+	//
+	// PUSH1 0x02 DUP1 PUSH1 0x0c RETURNDATASIZE CODECOPY
+	// PUSH1 0x02 RETURNDATASIZE RETURN INVALID + runtime
+	runtime, err = RuntimeCode(common.Hex2Bytes("600280600c3d396002" + "3df3fe" + "6000"))
+	if err != nil || common.Bytes2Hex(runtime) != "6000" {
+		t.Fatalf("incorrect result, want: 6000 got: %x (%v)", runtime, err)
+	}
+
+	for _, code := range []string{"", "6000600052", "60006000fd", "6010600060003960106000f3"} {
+		if _, err := RuntimeCode(common.Hex2Bytes(code)); err != ErrNoRuntimeCode {
+			t.Fatalf("incorrect result, want: %v got: %v (%s)", ErrNoRuntimeCode, err, code)
+		}
 	}
 }
@@ -0,0 +1,113 @@
+package grinder
+
+import (
+	"errors"
+
+	"github.com/dbadoy/grinder/pkg/disasm"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// ErrNoRuntimeCode is returned if the runtime code can not be
+// located in the creation code.
+var ErrNoRuntimeCode = errors.New("runtime code not found")
+
+// codeCopy is a CODECOPY with constant arguments.
+type codeCopy struct {
+	dest, offset, size uint64
+}
+
+// RuntimeCode returns the runtime code the constructor in the
+// creation code returns.
+//
+// The constructor copies the runtime code into memory with
+// CODECOPY and returns that memory with RETURN, e.g.
+//
+//	PUSH2 size DUP1 PUSH2 offset PUSH1 0x00 CODECOPY PUSH1 0x00 RETURN
+//
+// The arguments of both are resolved by a linear simulation of
+// the stack, and the copied section that is returned is the runtime
+// code. Immutable values written to memory by the constructor are
+// left as zero.
+func RuntimeCode(initcode []byte) ([]byte, error) {
+	var (
+		s      = new(flowState)
+		copies = make([]codeCopy, 0)
+
+		// RETURNDATASIZE is a cheap zero until the first call.
+		called = false
+	)
+
+	for _, ins := range disasm.Disassemble(initcode) {
+		op := ins.Op
+		switch {
+		case op.IsPush():
+			s.push(constant(ins.Value()))
+			continue
+
+		case op == vm.PUSH0:
+			s.push(slot{known: true})
+			continue
+
+		case op == vm.RETURNDATASIZE && !called:
+			s.push(slot{known: true})
+			continue
+
+		case op >= vm.DUP1 && op <= vm.DUP16:
+			s.push(s.peek(int(op - vm.DUP1)))
+			continue
+
+		case op >= vm.SWAP1 && op <= vm.SWAP16:
+			s.swap(int(op-vm.SWAP1) + 1)
+			continue
+
+		case op == vm.JUMPDEST:
+			// The block may be entered by a jump, the stack is
+			// not known.
+			s.stack = nil
+			continue
+
+		case op == vm.CODECOPY:
+			dest, offset, size := s.pop(), s.pop(), s.pop()
+			if dest.known && offset.known && size.known {
+				copies = append(copies, codeCopy{dest.value, offset.value, size.value})
+			}
+			continue
+
+		case op == vm.RETURN:
+			offset, size := s.pop(), s.pop()
+			if !offset.known || !size.known || size.value == 0 {
+				continue
+			}
+
+			for k := len(copies) - 1; k >= 0; k-- {
+				c := copies[k]
+				if c.dest != offset.value || c.size < size.value {
+					continue
+				}
+				if c.offset > uint64(len(initcode)) || size.value > uint64(len(initcode))-c.offset {
+					continue
+				}
+				return initcode[c.offset : c.offset+size.value], nil
+			}
+			continue
+
+		case op == vm.CALL || op == vm.CALLCODE || op == vm.DELEGATECALL || op == vm.STATICCALL ||
+			op == vm.CREATE || op == vm.CREATE2:
+			called = true
+		}
+
+		pop, push, ok := disasm.StackEffect(op)
+		if !ok {
+			s.stack = nil
+			continue
+		}
+		for k := 0; k < pop; k++ {
+			s.pop()
+		}
+		for k := 0; k < push; k++ {
+			s.push(slot{})
+		}
+	}
+
+	return nil, ErrNoRuntimeCode
+}
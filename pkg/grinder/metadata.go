@@ -0,0 +1,275 @@
+package grinder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Metadata is the CBOR-encoded section that Solidity and Vyper
+// append to the runtime code.
+//
+// https://docs.soliditylang.org/en/latest/metadata.html#encoding-of-the-metadata-hash-in-the-bytecode
+type Metadata struct {
+	// Compiler is the compiler name and version, e.g. "solc 0.8.18".
+	// Empty if the trailer does not carry it (solc < 0.5.9).
+	Compiler string
+
+	// Hash is the location of the source metadata file, e.g.
+	// "ipfs://Qm..." or "bzzr1://<hex>".
+	Hash string
+
+	// Experimental is set for code compiled with experimental
+	// features enabled.
+	Experimental bool
+}
+
+var errCBOR = errors.New("invalid cbor")
+
+// maxCBORDepth bounds the nesting of the decoded items.
+const maxCBORDepth = 4
+
+// SplitMetadata finds the metadata trailer by the 2-byte length
+// suffix at the end of the code. It returns the code without the
+// trailer and the decoded metadata, or the code as-is and nil if
+// there is no trailer.
+func SplitMetadata(bytecode []byte) ([]byte, *Metadata) {
+	if len(bytecode) < 2 {
+		return bytecode, nil
+	}
+
+	var (
+		size  = int(binary.BigEndian.Uint16(bytecode[len(bytecode)-2:]))
+		start = len(bytecode) - 2 - size
+	)
+	if size == 0 || start < 0 {
+		return bytecode, nil
+	}
+
+	d := &cborDecoder{data: bytecode[start : len(bytecode)-2]}
+	item, err := d.decode(0)
+	if err != nil || d.pos != len(d.data) {
+		return bytecode, nil
+	}
+
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return bytecode, nil
+	}
+
+	meta, ok := newMetadata(fields)
+	if !ok {
+		return bytecode, nil
+	}
+	return bytecode[:start], meta
+}
+
+func newMetadata(fields map[string]interface{}) (*Metadata, bool) {
+	var (
+		meta  = new(Metadata)
+		known = false
+	)
+
+	for key, value := range fields {
+		switch key {
+		case "ipfs":
+			if hash, ok := value.([]byte); ok {
+				meta.Hash = "ipfs://" + base58(hash)
+				known = true
+			}
+		case "bzzr0", "bzzr1":
+			if hash, ok := value.([]byte); ok {
+				meta.Hash = key + "://" + common.Bytes2Hex(hash)
+				known = true
+			}
+		case "solc":
+			if version, ok := compilerVersion(value); ok {
+				meta.Compiler = "solc " + version
+				known = true
+			}
+		case "vyper":
+			if version, ok := compilerVersion(value); ok {
+				meta.Compiler = "vyper " + version
+				known = true
+			}
+		case "experimental":
+			if b, ok := value.(bool); ok {
+				meta.Experimental = b
+				known = true
+			}
+		}
+	}
+
+	return meta, known
+}
+
+// compilerVersion formats the version of a release build (3 bytes
+// for solc, an array for vyper) or returns the version string of a
+// pre-release build as-is.
+func compilerVersion(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case []byte:
+		if len(v) != 3 {
+			return "", false
+		}
+		return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2]), true
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, p := range v {
+			n, ok := p.(uint64)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, fmt.Sprint(n))
+		}
+		return strings.Join(parts, "."), len(parts) > 0
+	case string:
+		return v, true
+	}
+	return "", false
+}
+
+// cborDecoder decodes the subset of CBOR used by the metadata:
+// unsigned integers, byte and text strings, arrays, maps with text
+// keys and booleans. Indefinite lengths are not supported.
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxCBORDepth || d.pos >= len(d.data) {
+		return nil, errCBOR
+	}
+
+	var (
+		major = d.data[d.pos] >> 5
+		info  = d.data[d.pos] & 0x1f
+	)
+	d.pos++
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		}
+		return nil, errCBOR
+	}
+
+	n, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		return n, nil
+
+	case 2, 3:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errCBOR
+		}
+		b := d.data[d.pos : d.pos+int(n)]
+		d.pos += int(n)
+
+		if major == 3 {
+			return string(b), nil
+		}
+		return b, nil
+
+	case 4:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errCBOR
+		}
+		arr := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, item)
+		}
+		return arr, nil
+
+	case 5:
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, errCBOR
+		}
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, errCBOR
+			}
+
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = value
+		}
+		return m, nil
+	}
+
+	return nil, errCBOR
+}
+
+// argument reads the length or value that follows the initial byte.
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	if info < 24 {
+		return uint64(info), nil
+	}
+	if info > 27 {
+		return 0, errCBOR
+	}
+
+	size := 1 << (info - 24)
+	if d.pos+size > len(d.data) {
+		return 0, errCBOR
+	}
+
+	var n uint64
+	for _, b := range d.data[d.pos : d.pos+size] {
+		n = n<<8 | uint64(b)
+	}
+	d.pos += size
+	return n, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58 encodes the IPFS multihash the way CIDv0 does.
+func base58(b []byte) string {
+	var (
+		n    = new(big.Int).SetBytes(b)
+		mod  = new(big.Int)
+		base = big.NewInt(58)
+		res  = make([]byte, 0, len(b)*138/100+1)
+	)
+
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		res = append(res, base58Alphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		res = append(res, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return string(res)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/dbadoy/grinder/pkg/checkpoint"
+	"github.com/dbadoy/grinder/pkg/database"
+	"github.com/dbadoy/grinder/server/dto"
+)
+
+// writeBatch queues the writes of a block or a request, and the
+// journals to revert them in the same order.
+type writeBatch struct {
+	database.Batch
+	journals []journalObject
+
+	// contracts are the queued contracts by key. They can't be
+	// read from the engine until the batch is written.
+	contracts map[string]*dto.Contract
+}
+
+func (s *Server) newBatch() *writeBatch {
+	return &writeBatch{
+		Batch:     s.engine.NewBatch(),
+		journals:  make([]journalObject, 0),
+		contracts: make(map[string]*dto.Contract),
+	}
+}
+
+// insert queues an insert of a key that does not exist.
+func (b *writeBatch) insert(key []byte, data database.Data) {
+	b.Batch.Insert(key, data)
+	b.journals = append(b.journals, &insertData{data.Index(), key})
+}
+
+// put queues a put, prev is the journal that restores the previous
+// data.
+func (b *writeBatch) put(key []byte, data database.Data, prev *putData) {
+	b.Batch.Put(key, data)
+	b.journals = append(b.journals, prev)
+}
+
+// write writes the batch with write, e.g. Batch.Write. The journals
+// of the operations that succeeded are appended to the 'journals'.
+func (s *Server) write(b *writeBatch, write func() ([]error, error)) error {
+	if err := s.prepare(b.journals...); err != nil {
+		return err
+	}
+
+	results, err := write()
+	if err != nil {
+		// Any of the operations may have been written.
+		s.journals = append(s.journals, b.journals...)
+		return fmt.Errorf("request failed in database: %v", err)
+	}
+
+	for i, res := range results {
+		if res == nil {
+			s.journals = append(s.journals, b.journals[i])
+		}
+	}
+
+	var failed error
+	for _, res := range results {
+		// The aborted operations are not the cause of the failure.
+		if res != nil && (failed == nil || failed == database.ErrAborted) {
+			failed = res
+		}
+	}
+	if failed != nil {
+		return fmt.Errorf("request failed in database: %v", failed)
+	}
+
+	return nil
+}
+
+// commitBlock writes the batch together with the checkpoint marker
+// of block n.
+func (s *Server) commitBlock(b *writeBatch, n uint64) error {
+	marker, err := s.putJournal(checkpoint.MarkerIndex, checkpoint.MarkerKey)
+	if err != nil {
+		return err
+	}
+
+	// A reverted block must not leave its marker behind, so the
+	// marker is journaled before any write.
+	if err := s.prepare(marker); err != nil {
+		return err
+	}
+	s.journals = append(s.journals, marker)
+
+	return s.write(b, func() ([]error, error) {
+		return s.engine.Commit(b.Batch, n)
+	})
+}
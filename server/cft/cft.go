@@ -6,6 +6,7 @@ import (
 
 	"github.com/dbadoy/grinder/pkg/checkpoint"
 	"github.com/dbadoy/grinder/pkg/database"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 var (
@@ -34,18 +35,50 @@ func (c *CFT) Put(key []byte, data database.Data) error {
 	return c.db.Put(key, data)
 }
 
-func (c *CFT) Delete(key []byte) error {
+func (c *CFT) Delete(index string, key []byte) error {
 	if !c.srv.HasLeaderPermissions() {
 		return errors.New("foo")
 	}
 
-	return c.db.Delete(key)
+	return c.db.Delete(index, key)
 }
 
 func (c *CFT) Exist(index string, key []byte) (bool, error) {
 	return c.db.Exist(index, key)
 }
 
+func (c *CFT) Get(key []byte, data database.Data) error {
+	return c.db.Get(key, data)
+}
+
+func (c *CFT) MultiGet(index string, keys [][]byte) ([]*database.Document, error) {
+	return c.db.MultiGet(index, keys)
+}
+
+func (c *CFT) Scan(index string, offset int, limit int) ([]*database.Document, error) {
+	return c.db.Scan(index, offset, limit)
+}
+
+func (c *CFT) Count(index string) (int, error) {
+	return c.db.Count(index)
+}
+
+func (c *CFT) Search(index string, field string, terms []string) ([]*database.Document, error) {
+	return c.db.Search(index, field, terms)
+}
+
+func (c *CFT) NewBatch() database.Batch {
+	return c.db.NewBatch()
+}
+
+func (c *CFT) Commit(batch database.Batch, n uint64) ([]error, error) {
+	if !c.srv.HasLeaderPermissions() {
+		return nil, errors.New("foo")
+	}
+
+	return commit(c.db, batch, n)
+}
+
 func (c *CFT) Checkpoint() uint64 {
 	return c.cp.Checkpoint()
 }
@@ -54,6 +87,12 @@ func (c *CFT) SetCheckpoint(cp uint64) error {
 	return c.srv.CommitCheckpoint(cp)
 }
 
+// SetCheckpointHash commits the number only, the cluster does not
+// replicate the hash.
+func (c *CFT) SetCheckpointHash(n uint64, hash common.Hash) error {
+	return c.srv.CommitCheckpoint(n)
+}
+
 func (c *CFT) Increase() error {
 	return c.srv.CommitCheckpoint(c.cp.Checkpoint() + 1)
 }
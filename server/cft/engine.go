@@ -1,21 +1,37 @@
 package cft
 
 import (
+	"fmt"
 	"net"
 
+	"github.com/dbadoy/grinder/pkg/checkpoint"
 	"github.com/dbadoy/grinder/pkg/database"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 type Engine interface {
 	// Database
 	Insert(key []byte, data database.Data) error
 	Put(key []byte, data database.Data) error
-	Delete(key []byte) error
+	Delete(index string, key []byte) error
 	Exist(index string, key []byte) (bool, error)
+	Get(key []byte, data database.Data) error
+	MultiGet(index string, keys [][]byte) ([]*database.Document, error)
+	Scan(index string, offset int, limit int) ([]*database.Document, error)
+	Count(index string) (int, error)
+	Search(index string, field string, terms []string) ([]*database.Document, error)
+	NewBatch() database.Batch
+
+	// Commit writes the batch together with the checkpoint marker
+	// of block n. The i-th result is the result of the i-th queued
+	// operation. If any of them failed, the marker is put back to
+	// n-1. The checkpoint itself is not advanced.
+	Commit(batch database.Batch, n uint64) ([]error, error)
 
 	// Checkpoint
 	Checkpoint() uint64
 	SetCheckpoint(uint64) error
+	SetCheckpointHash(n uint64, hash common.Hash) error
 	Increase() error
 	Decrease() error
 }
@@ -29,3 +45,38 @@ type server interface {
 
 	CommitCheckpoint(uint64) error
 }
+
+// commit writes the batch with the checkpoint marker of block n as
+// its last operation. A backend that writes the batch atomically
+// stores the documents and the marker together or none of them,
+// the others at least never store the marker before the documents.
+func commit(db database.Database, batch database.Batch, n uint64) ([]error, error) {
+	batch.Put(checkpoint.MarkerKey, &checkpoint.Marker{Number: n})
+
+	results, err := batch.Write()
+	if err != nil {
+		return nil, err
+	}
+
+	results, marker := results[:len(results)-1], results[len(results)-1]
+	if marker == database.ErrAborted {
+		// The batch is atomic, nothing was written.
+		return results, nil
+	}
+	if marker != nil {
+		return nil, fmt.Errorf("checkpoint marker write failed: %v", marker)
+	}
+
+	for _, res := range results {
+		if res != nil {
+			// The batch is not atomic, the block is incomplete
+			// and will be handled again.
+			if err := db.Put(checkpoint.MarkerKey, &checkpoint.Marker{Number: n - 1}); err != nil {
+				return nil, fmt.Errorf("checkpoint marker write failed: %v", err)
+			}
+			break
+		}
+	}
+
+	return results, nil
+}
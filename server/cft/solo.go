@@ -5,6 +5,7 @@ import (
 
 	"github.com/dbadoy/grinder/pkg/checkpoint"
 	"github.com/dbadoy/grinder/pkg/database"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 var _ Engine = (*Solo)(nil)
@@ -19,3 +20,11 @@ type Solo struct {
 func NewSoloEngine(local net.Addr, db database.Database, cp checkpoint.CheckpointHandler) (Engine, error) {
 	return &Solo{local, db, cp}, nil
 }
+
+func (s *Solo) Commit(batch database.Batch, n uint64) ([]error, error) {
+	return commit(s.Database, batch, n)
+}
+
+func (s *Solo) SetCheckpointHash(n uint64, hash common.Hash) error {
+	return checkpoint.SetCheckpointHash(s.CheckpointHandler, n, hash)
+}
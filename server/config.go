@@ -1,9 +1,39 @@
 package server
 
+import "github.com/dbadoy/grinder/server/proxy"
+
 type Config struct {
 	AllowProxyContract bool
+
+	// ProxyDetectors are the names of the proxy detectors to run
+	// if AllowProxyContract is set. proxy.DefaultDetectors are
+	// used if it is empty.
+	ProxyDetectors []string
+
+	// AllowInternalContract enables the discovery of the contracts
+	// deployed by other contracts (CREATE/CREATE2). The node must
+	// serve debug_traceBlockByNumber.
+	AllowInternalContract bool
+
+	// JournalPath is the file the journals of the ongoing block or
+	// request are persisted to, so that a partially written block
+	// is reverted by the next start. Disabled if empty.
+	JournalPath string
 }
 
 func (c *Config) validate() error {
-	return nil
+	_, err := c.proxyDetectors()
+	return err
+}
+
+func (c *Config) proxyDetectors() ([]proxy.Detector, error) {
+	if !c.AllowProxyContract {
+		return nil, nil
+	}
+
+	names := c.ProxyDetectors
+	if len(names) == 0 {
+		names = proxy.DefaultDetectors
+	}
+	return proxy.Lookup(names...)
 }
@@ -1,6 +1,11 @@
 package dto
 
-import "github.com/ethereum/go-ethereum/accounts/abi"
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
 
 type ABI struct {
 	MethodIDs  []string
@@ -13,24 +18,44 @@ func (ABI) Index() string {
 	return "abis"
 }
 
+// IDs returns the method and event IDs of the ABI in the same
+// form as Contract.Candidates.
+func (a *ABI) IDs() []string {
+	ids := make([]string, 0, len(a.MethodIDs)+len(a.EventIDs))
+	for _, id := range a.MethodIDs {
+		ids = append(ids, NormalizeID(id))
+	}
+	for _, id := range a.EventIDs {
+		ids = append(ids, NormalizeID(id))
+	}
+	return ids
+}
+
+// NormalizeID converts a method or event ID to the form stored in
+// Contract.Candidates: lower case hex without the '0x' prefix.
+func NormalizeID(id string) string {
+	id = strings.ToLower(id)
+	return strings.TrimPrefix(id, "0x")
+}
+
 func PackABI(abi *abi.ABI) *ABI {
 	var (
 		methods = abi.Methods
 		events  = abi.Events
 
-		mids  = make([]string, len(methods))
-		msigs = make([]string, len(methods))
-		eids  = make([]string, len(methods))
-		esigs = make([]string, len(methods))
+		mids  = make([]string, 0, len(methods))
+		msigs = make([]string, 0, len(methods))
+		eids  = make([]string, 0, len(events))
+		esigs = make([]string, 0, len(events))
 	)
 
 	for _, method := range methods {
-		mids = append(mids, string(method.ID))
+		mids = append(mids, common.Bytes2Hex(method.ID))
 		msigs = append(msigs, method.Sig)
 	}
 
 	for _, event := range events {
-		eids = append(eids, event.ID.Hex())
+		eids = append(eids, common.Bytes2Hex(event.ID.Bytes()))
 		esigs = append(esigs, event.Sig)
 	}
 
@@ -4,11 +4,70 @@ type Contract struct {
 	TxHash     string
 	Candidates []string
 
+	// Constants are the 4-byte values that are not dispatched as
+	// methods (e.g. custom errors, interface IDs).
+	Constants []string
+
+	// Hashes are the 32-byte values that are not used as event
+	// topics (e.g. storage slots, EIP-712 type hashes).
+	Hashes []string
+
+	// Compiler and MetadataHash are decoded from the metadata
+	// trailer, e.g. "solc 0.8.18" and "ipfs://Qm...". Empty if
+	// the code does not have one.
+	Compiler     string
+	MetadataHash string
+
 	// We need to determine which logic contract
 	// the Proxy contract is connected to.
 	RelateAddress []string
+
+	// Relations are every address the proxy relates to, including
+	// the ones that are not contracts (e.g. an EOA admin).
+	Relations []Relation
+
+	// Upgrades is the history of the EIP-1967 Upgraded,
+	// AdminChanged and BeaconUpgraded events of the proxy.
+	Upgrades []Upgrade
+
+	// Parent is the factory contract that deployed the contract.
+	// Empty if it was deployed by a transaction.
+	Parent string
+
+	// FromInitCode is true if the code was already gone, and the
+	// metadata was taken from the runtime code in the creation
+	// code. Immutable values are zero in it.
+	FromInitCode bool
+
+	// Failed is true if the deployment transaction reverted.
+	// Nothing but TxHash is set then.
+	Failed bool
+}
+
+// Kinds of the related accounts.
+const (
+	KindContract = "contract"
+	KindEOA      = "eoa"
+)
+
+type Relation struct {
+	Address string
+
+	// Role is the role of the address for the proxy, e.g.
+	// "admin" or "implementation".
+	Role string
+
+	// Kind is KindContract or KindEOA.
+	Kind string
 }
 
 func (Contract) Index() string {
 	return "contracts"
 }
+
+type Upgrade struct {
+	// Block is the number of the block the event was emitted in.
+	Block   uint64
+	Role    string
+	Address string
+}
@@ -8,27 +8,65 @@ import (
 	"github.com/dbadoy/grinder/pkg/database"
 	"github.com/dbadoy/grinder/pkg/grinder"
 	"github.com/dbadoy/grinder/server/dto"
+	"github.com/dbadoy/grinder/server/proxy"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-func (s *Server) handleBlock(block *types.Block) (err error) {
+func (s *Server) handleBlock(block *types.Block) error {
+	if err := s.writeBlock(block); err != nil {
+		return err
+	}
+
+	// The documents are stored with the marker of the block. If
+	// the checkpoint fails to be advanced, reconcile catches up.
+	return s.engine.SetCheckpointHash(block.NumberU64(), block.Hash())
+}
+
+// writeBlock writes the documents of the block and its checkpoint
+// marker with a single batch.
+func (s *Server) writeBlock(block *types.Block) (err error) {
+	if err := s.retryReverts(); err != nil {
+		return err
+	}
+
 	defer func() {
 		if err != nil {
-			s.revert()
+			if rerr := s.revert(); rerr != nil {
+				err = fmt.Errorf("%v (%v)", err, rerr)
+			}
+		} else {
+			err = s.commit()
 		}
 	}()
 
-	return s.handleTransactions(block.Transactions())
+	b := s.newBatch()
+
+	if err := s.handleTransactions(b, block); err != nil {
+		return err
+	}
+
+	if err := s.handleUpgrades(b, block.NumberU64()); err != nil {
+		return err
+	}
+
+	return s.commitBlock(b, block.NumberU64())
 }
 
-func (s *Server) handleTransactions(txs types.Transactions) (err error) {
+func (s *Server) handleTransactions(b *writeBatch, block *types.Block) (err error) {
+	var (
+		txs      = block.Transactions()
+		pendings = make([]*pendingContract, 0)
+	)
+
 	for _, tx := range txs {
-		if ca, err := contractAddress(tx); err == nil {
-			// Do handleContract if it is a deployment transaction.
-			if err := s.handleContract(tx.Hash(), ca); err != nil {
+		if tx.To() == nil {
+			// Do grindContract if it is a deployment transaction.
+			contracts, err := s.grindDeployment(tx)
+			if err != nil {
 				return err
 			}
+			pendings = append(pendings, contracts...)
 		}
 
 		/*
@@ -36,99 +74,327 @@ func (s *Server) handleTransactions(txs types.Transactions) (err error) {
 		*/
 	}
 
-	return nil
+	if s.cfg.AllowInternalContract {
+		internals, err := s.grindInternalContracts(block)
+		if err != nil {
+			return err
+		}
+		pendings = append(pendings, internals...)
+	}
+
+	// The contracts of a block are written together, so that the
+	// database receives a single request per block.
+	return s.queueContracts(b, pendings)
+}
+
+// pendingContract is a ground contract waiting to be written.
+type pendingContract struct {
+	address common.Address
+	data    *dto.Contract
+
+	// required is false for the contracts related to a proxy,
+	// which may have been stored by a previous request.
+	required bool
+
+	// redeployable is true for the contracts created by another
+	// contract. The address can be deployed again after the
+	// contract is destroyed (e.g. CREATE2 with the same salt), the
+	// stored deployment is then overwritten.
+	redeployable bool
+}
+
+// grindDeployment grinds the contract created by the deployment
+// transaction. The address and the result are taken from the
+// receipt, a failed deployment is recorded as failed.
+func (s *Server) grindDeployment(tx *types.Transaction) ([]*pendingContract, error) {
+	receipt, err := s.eth.GetTransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		// The nonce is consumed, no contract will ever be
+		// deployed to the address.
+		contract := &dto.Contract{TxHash: tx.Hash().Hex(), Failed: true}
+		return []*pendingContract{{receipt.ContractAddress, contract, true, false}}, nil
+	}
+
+	contracts, err := s.grindContract(tx.Hash(), receipt.ContractAddress)
+	if err == grinder.ErrNotContract {
+		// The contract destroyed itself in the constructor, or
+		// before the block is handled.
+		return grindInitCode(tx.Hash(), receipt.ContractAddress, tx.Data())
+	}
+
+	return contracts, err
 }
 
 func (s *Server) handleContract(hash common.Hash, ca common.Address) error {
-	var (
-		cas = make([]common.Address, 1)
-	)
+	contracts, err := s.grindContract(hash, ca)
+	if err != nil {
+		return err
+	}
 
+	return s.writeContracts(contracts)
+}
+
+// grindContract extracts the metadata of the contract and the
+// contracts related to it. grinder.ErrNotContract is returned if
+// the address has no code.
+func (s *Server) grindContract(hash common.Hash, ca common.Address) ([]*pendingContract, error) {
 	// tx.Data also contains initialization code that will never
 	// be used again, we use CodeAt to store the bytecode.
 	//
 	// tx.Data = initial code + byte code
-	cas[0] = ca
+	code, err := s.eth.CodeAt(context.Background(), ca, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	if s.cfg.AllowProxyContract {
-		// == Transparent Proxy
-		if admin, impl, err := s.eip1967(ca); err == nil {
-			cas = append(cas, admin, impl)
-		}
+	contract, err := grindCode(hash, code)
+	if err != nil {
+		return nil, err
+	}
 
-		// == UUPS Proxy
-		if logic, err := s.eip1822(ca); err == nil {
-			cas = append(cas, logic)
-		}
+	relations, err := s.detectProxy(ca)
+	if err != nil {
+		return nil, err
 	}
 
-	for idx, addr := range cas {
-		code, err := s.eth.CodeAt(context.Background(), addr, nil)
+	contracts := []*pendingContract{{ca, contract, true, false}}
+
+	// Admins are often EOAs, only the related contracts are
+	// ground. Every relation is kept on the proxy with its role
+	// and account type.
+	for _, relation := range relations {
+		code, err := s.eth.CodeAt(context.Background(), relation.Address, nil)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		kind := dto.KindContract
+		if len(code) == 0 {
+			kind = dto.KindEOA
+		}
+
+		contract.Relations = append(contract.Relations, dto.Relation{
+			Address: relation.Address.Hex(),
+			Role:    string(relation.Role),
+			Kind:    kind,
+		})
+
+		if kind == dto.KindEOA {
+			continue
 		}
 
-		methods, events, err := grinder.Grinde(code)
+		related, err := grindCode(hash, code)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		r := make([]string, len(methods)+len(events))
-		copy(r[0:], methods)
-		copy(r[len(methods):], events)
+		contract.RelateAddress = append(contract.RelateAddress, relation.Address.Hex())
+		contracts = append(contracts, &pendingContract{relation.Address, related, false, false})
+	}
+
+	return contracts, nil
+}
+
+// grindInitCode is the fallback of grindContract for a contract
+// whose code is already gone, e.g. it destroyed itself in the same
+// block. The runtime code is taken from the creation code instead.
+func grindInitCode(hash common.Hash, ca common.Address, initcode []byte) ([]*pendingContract, error) {
+	runtime, err := grinder.RuntimeCode(initcode)
+	if err == grinder.ErrNoRuntimeCode {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contract, err := grindCode(hash, runtime)
+	if err == grinder.ErrNotContract {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	contract.FromInitCode = true
+
+	return []*pendingContract{{ca, contract, true, false}}, nil
+}
 
-		contractDTO := &dto.Contract{
-			TxHash:        hash.Hex(),
-			Candidates:    r,
-			RelateAddress: nil,
+// detectProxy runs the proxy detectors on the contract, and
+// returns the addresses it relates to.
+func (s *Server) detectProxy(ca common.Address) ([]proxy.Relation, error) {
+	var (
+		relations = make([]proxy.Relation, 0)
+		seen      = map[common.Address]struct{}{ca: {}}
+	)
+
+	for _, detector := range s.detectors {
+		related, err := detector.Detect(context.Background(), s.eth, ca)
+		if err == proxy.ErrNotProxy {
+			continue
+		}
+		if err != nil {
+			return nil, err
 		}
 
-		// If 'cas' is greater than 1, an implement or logic
-		// contract exists. Append these related addresses to
-		// 'dto.Contract.RelateAddress'.
-		if len(cas) > 1 && idx == 0 /* To Proxy contract */ {
-			related := cas[1:]
-			contractDTO.RelateAddress = make([]string, 0, len(related))
-			for _, addr := range related {
-				contractDTO.RelateAddress = append(contractDTO.RelateAddress, addr.Hex())
+		for _, relation := range related {
+			if _, ok := seen[relation.Address]; ok {
+				continue
 			}
+			seen[relation.Address] = struct{}{}
+			relations = append(relations, relation)
+		}
+	}
+
+	return relations, nil
+}
+
+// grindCode builds the contract metadata from the code.
+func grindCode(hash common.Hash, code []byte) (*dto.Contract, error) {
+	res, err := grinder.Analyze(code)
+	if err != nil {
+		return nil, err
+	}
+
+	// If the dispatcher is not recognised, fall back to every
+	// 4-byte constant so the methods are still searchable.
+	methods := res.Methods
+	if len(methods) == 0 {
+		methods = res.Constants
+	}
+
+	r := make([]string, len(methods)+len(res.Events))
+	copy(r[0:], methods)
+	copy(r[len(methods):], res.Events)
+
+	contract := &dto.Contract{
+		TxHash:     hash.Hex(),
+		Candidates: r,
+		Constants:  res.Constants,
+		Hashes:     res.Hashes,
+	}
+
+	if res.Metadata != nil {
+		contract.Compiler = res.Metadata.Compiler
+		contract.MetadataHash = res.Metadata.Hash
+	}
+
+	return contract, nil
+}
+
+// writeContracts inserts the contracts with a single batch. Only
+// the contracts that were actually written are appended to the
+// 'journals', so a revert never removes data stored by a previous
+// request.
+func (s *Server) writeContracts(contracts []*pendingContract) error {
+	b := s.newBatch()
+	if err := s.queueContracts(b, contracts); err != nil {
+		return err
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+	return s.write(b, b.Write)
+}
+
+// queueContracts queues the contracts that are not stored yet.
+//
+// Proxy pattern allows different contracts to point to the same
+// implementation contract, so the related contracts may have been
+// stored by a previous request. A newly deployed contract is only
+// stored if the block is handled again (e.g. after a crash), it
+// must then be the same deployment, unless it was created by
+// another contract and deployed again.
+func (s *Server) queueContracts(b *writeBatch, contracts []*pendingContract) error {
+	for _, contract := range uniqueContracts(contracts) {
+		key := []byte(contract.address.Hex())
+		if _, ok := b.contracts[string(key)]; ok {
+			continue
 		}
 
-		err = s.engine.Insert([]byte(addr.Hex()), contractDTO)
+		ok, err := s.engine.Exist(contract.data.Index(), key)
 		if err != nil {
-			// Proxy pattern allows different contracts to point to the
-			// same implementation contract, so we ignores 'ErrAlreadyExist'.
-			//
-			// This data is saved from a previous request and should not be
-			// reverted if the request fails. Skip without appending to the
-			// 'journals'.
-			if errors.Is(err, database.ErrAlreadyExist) {
-				// But idx 0 is a newly deployed contract, so it shouldn't fail.
-				if idx != 0 {
-					continue
-				}
-			}
+			return fmt.Errorf("request failed in database: %v", err)
+		}
+
+		if !ok {
+			b.insert(key, contract.data)
+			b.contracts[string(key)] = contract.data
+			continue
+		}
+
+		if !contract.required {
+			continue
+		}
 
+		stored := new(dto.Contract)
+		if err := s.engine.Get(key, stored); err != nil {
 			return fmt.Errorf("request failed in database: %v", err)
 		}
+		if stored.TxHash == contract.data.TxHash {
+			continue
+		}
+		if !contract.redeployable {
+			return fmt.Errorf("request failed in database: %v (%s)", database.ErrAlreadyExist, contract.address.Hex())
+		}
 
-		s.journals = append(s.journals, &insertContract{[]byte(addr.Hex())})
+		// The stored contract was destroyed and the address was
+		// deployed again.
+		prev, err := s.putJournal(contract.data.Index(), key)
+		if err != nil {
+			return fmt.Errorf("request failed in database: %v", err)
+		}
+		b.put(key, contract.data, prev)
+		b.contracts[string(key)] = contract.data
 	}
 
 	return nil
 }
 
+// uniqueContracts keeps a single entry per address. A contract
+// can be both deployed and related to a proxy in the same block,
+// the deployed one is kept. A contract deployed again in the same
+// block keeps the last deployment.
+func uniqueContracts(contracts []*pendingContract) []*pendingContract {
+	index := make(map[common.Address]int, len(contracts))
+	res := make([]*pendingContract, 0, len(contracts))
+
+	for _, contract := range contracts {
+		i, ok := index[contract.address]
+		if !ok {
+			index[contract.address] = len(res)
+			res = append(res, contract)
+			continue
+		}
+
+		if contract.redeployable || (contract.required && !res[i].required) {
+			res[i] = contract
+		}
+	}
+
+	return res
+}
+
 func (s *Server) handleRequest(req request) {
 	if req.Errorc() == nil {
 		panic("bad Server.request: empty error channel")
 	}
 
+	if err := s.retryReverts(); err != nil {
+		req.Errorc() <- err
+		return
+	}
+
 	var err error
 
 	switch req.Kind() {
 	case abiRequestType:
 		abi := req.(*ABIRequest)
-		err = s.engine.Insert([]byte(abi.Name), abi.ABI)
+		err = s.insert([]byte(abi.Name), abi.ABI)
 
 	case contractRequestType:
 		contract := req.(*ContractRequest)
@@ -139,7 +405,11 @@ func (s *Server) handleRequest(req request) {
 	}
 
 	if err != nil {
-		s.revert()
+		if rerr := s.revert(); rerr != nil {
+			err = fmt.Errorf("%v (%v)", err, rerr)
+		}
+	} else {
+		err = s.commit()
 	}
 
 	req.Errorc() <- err
@@ -148,18 +418,74 @@ func (s *Server) handleRequest(req request) {
 // revert performs a revert to a previous state if an
 // intermediate failure occurs when making multiple
 // requests to the engine within a single request.
-func (s *Server) revert() {
-	for _, task := range s.journals {
+//
+// An error is returned if the journals that failed to be reverted
+// can't be persisted, they would be lost by a stop.
+func (s *Server) revert() error {
+	failed := make([]journalObject, 0)
+
+	// A key may be written more than once, so the journals are
+	// reverted from the last one.
+	for i := len(s.journals) - 1; i >= 0; i-- {
+		task := s.journals[i]
+
 		// If a database error occurs, the delete request will likely
-		// fail as well.
-		//
-		// TODO(dbadoy): We can leave it as a file and perform the
-		// Revert when the server is restarted after the DB is
-		// recovered.
-		task.revert(s.engine)
+		// fail as well. The journal is then kept in the file, and
+		// reverted by retryReverts before the next write, or by
+		// the next start.
+		if err := task.revert(s.engine); err != nil {
+			failed = append([]journalObject{task}, failed...)
+		}
+	}
+
+	if len(s.journals) != 0 {
+		s.journals = make([]journalObject, 0)
+	}
+
+	if s.wal != nil {
+		s.wal.keep(failed...)
+
+		if err := s.wal.reset(); err != nil {
+			return fmt.Errorf("journal write failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// retryReverts reverts the journals that failed to be reverted
+// before. Nothing may be written until they are, a write of the
+// same key would be undone by them later.
+func (s *Server) retryReverts() error {
+	if s.wal == nil {
+		return nil
+	}
+
+	if err := s.wal.retry(s.engine); err != nil {
+		return fmt.Errorf("journal revert failed: %v", err)
 	}
+	return nil
+}
 
+// commit discards the 'journals' of a request that has succeeded,
+// its data must not be reverted by a later failure.
+func (s *Server) commit() error {
 	if len(s.journals) != 0 {
 		s.journals = make([]journalObject, 0)
 	}
+
+	if s.wal != nil {
+		return s.wal.reset()
+	}
+	return nil
+}
+
+// prepare persists the journals of the writes that are about to be
+// made, so that they are reverted even if the server stops before
+// the request is done.
+func (s *Server) prepare(journals ...journalObject) error {
+	if s.wal == nil {
+		return nil
+	}
+	return s.wal.append(journals...)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/dbadoy/grinder/pkg/checkpoint"
 	"github.com/dbadoy/grinder/pkg/database/memdb"
 	"github.com/dbadoy/grinder/pkg/ethclient/mock"
+	"github.com/dbadoy/grinder/pkg/grinder"
 	"github.com/dbadoy/grinder/server/cft"
 	"github.com/dbadoy/grinder/server/dto"
 	"github.com/dbadoy/grinder/server/fetcher"
@@ -47,17 +48,20 @@ func TestHandleContract(t *testing.T) {
 	}
 
 	for _, tx := range txs {
-		ca, err := contractAddress(tx)
+		receipt, err := client.GetTransactionReceipt(context.Background(), tx.Hash())
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		if err := s.handleContract(tx.Hash(), ca); err != nil {
+		if err := s.handleContract(tx.Hash(), receipt.ContractAddress); err != nil {
 			t.Fatal(err)
 		}
 	}
 
-	meta := memdb.Get([]byte(ca.Hex())).(*dto.Contract)
+	meta := new(dto.Contract)
+	if err := memdb.Get([]byte(ca.Hex()), meta); err != nil {
+		t.Fatal(err)
+	}
 	if len(meta.Candidates) != 2 {
 		t.Fatalf("TestProcessContract, want: 2 got: %d", len(meta.Candidates))
 	}
@@ -101,7 +105,10 @@ func TestHandleABIRequest(t *testing.T) {
 	s.handleRequest(&ABIRequest{Name: name, ABI: input, errc: errc})
 	<-errc
 
-	abi := memdb.Get([]byte(name)).(*dto.ABI)
+	abi := new(dto.ABI)
+	if err := memdb.Get([]byte(name), abi); err != nil {
+		t.Fatal(err)
+	}
 	if len(abi.MethodIDs) != 2 || len(abi.EventIDs) != 2 {
 		t.Fatalf("TestHandleABIRequest, want: (MethodIDs 2 EventIDs 2) got: (MethodIDs %d EventIDs %d)", len(abi.MethodIDs), len(abi.EventIDs))
 	}
@@ -146,8 +153,244 @@ func TestHandleContractRequest(t *testing.T) {
 	s.handleRequest(input)
 	<-errc
 
-	contract := memdb.Get([]byte(ca.Hex())).(*dto.Contract)
+	contract := new(dto.Contract)
+	if err := memdb.Get([]byte(ca.Hex()), contract); err != nil {
+		t.Fatal(err)
+	}
 	if len(contract.Candidates) != 2 {
 		t.Fatalf("TestHandleContractRequest, want: 2 got: %d", len(contract.Candidates))
 	}
+	if contract.Compiler != "solc 0.8.18" {
+		t.Fatalf("TestHandleContractRequest, want: solc 0.8.18 got: %s", contract.Compiler)
+	}
+
+	// An address without code is rejected.
+	size := memdb.Size()
+
+	s.handleRequest(&ContractRequest{Address: common.HexToAddress("0xdead"), errc: errc})
+	if err := <-errc; err != grinder.ErrNotContract {
+		t.Fatalf("TestHandleContractRequest, want: %v got: %v", grinder.ErrNotContract, err)
+	}
+	if memdb.Size() != size {
+		t.Fatalf("TestHandleContractRequest, want: %d got: %d", size, memdb.Size())
+	}
+}
+
+func TestHandleBlock(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "handler")
+		memdb     = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, memdb, cp)
+
+		s, _ = New(client, fetcher, engine, cp, &Config{AllowProxyContract: false})
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	// Remix Storage.sol
+	ca, err := mock.DeployContract(client, common.Hex2Bytes("608060405234801561001057600080fd5b50610150806100206000396000f3fe608060405234801561001057600080fd5b50600436106100365760003560e01c80632e64cec11461003b5780636057361d14610059575b600080fd5b610043610075565b60405161005091906100a1565b60405180910390f35b610073600480360381019061006e91906100ed565b61007e565b005b60008054905090565b8060008190555050565b6000819050919050565b61009b81610088565b82525050565b60006020820190506100b66000830184610092565b92915050565b600080fd5b6100ca81610088565b81146100d557600080fd5b50565b6000813590506100e7816100c1565b92915050565b600060208284031215610103576101026100bc565b5b6000610111848285016100d8565b9150509291505056fea2646970667358221220322c78243e61b783558509c9cc22cb8493dde6925aa5e89a08cdf6e22f279ef164736f6c63430008120033"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := client.BlockByNumber(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.handleBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	marker := new(checkpoint.Marker)
+	if err := memdb.Get(checkpoint.MarkerKey, marker); err != nil || marker.Number != 1 || cp.Checkpoint() != 1 {
+		t.Fatalf("TestHandleBlock, want: (1 1) got: (%d %d) (%v)", marker.Number, cp.Checkpoint(), err)
+	}
+	if cp.Hash() != block.Hash() {
+		t.Fatalf("TestHandleBlock, want: %s got: %s", block.Hash().Hex(), cp.Hash().Hex())
+	}
+
+	// The block is handled again after a crash, it must neither
+	// fail nor remove the stored contract.
+	if err := s.handleBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := memdb.Exist(new(dto.Contract).Index(), []byte(ca.Hex())); !ok {
+		t.Fatalf("TestHandleBlock, want: exist got: not exist")
+	}
+
+	// The checkpoint failed to be advanced after the commit.
+	if err := cp.SetCheckpoint(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Checkpoint() != 1 {
+		t.Fatalf("TestHandleBlock, want: 1 got: %d", cp.Checkpoint())
+	}
+}
+
+func TestHandleFailedDeployment(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "handler")
+		memdb     = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, memdb, cp)
+
+		s, _ = New(client, fetcher, engine, cp, &Config{AllowProxyContract: false})
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	// PUSH1 0x00 PUSH1 0x00 REVERT
+	if _, err := mock.DeployContract(client, common.Hex2Bytes("60006000fd")); err == nil {
+		t.Fatal("TestHandleFailedDeployment, want: failed got: success")
+	}
+
+	block, err := client.BlockByNumber(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.handleBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	receipt, err := client.GetTransactionReceipt(context.Background(), block.Transactions()[0].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contract := new(dto.Contract)
+	if err := memdb.Get([]byte(receipt.ContractAddress.Hex()), contract); err != nil {
+		t.Fatal(err)
+	}
+	if !contract.Failed || len(contract.Candidates) != 0 {
+		t.Fatalf("TestHandleFailedDeployment, want: (true 0) got: (%v %d)", contract.Failed, len(contract.Candidates))
+	}
+}
+
+func TestHandleDestroyedContract(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "handler")
+		memdb     = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, memdb, cp)
+
+		s, _ = New(client, fetcher, engine, cp, &Config{AllowProxyContract: false})
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	// CALLER SELFDESTRUCT
+	runtime := []byte{0x33, 0xff}
+
+	// PUSH1 len DUP1 PUSH1 0x0b PUSH1 0x00 CODECOPY PUSH1 0x00 RETURN
+	creation := append([]byte{0x60, byte(len(runtime)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}, runtime...)
+
+	ca, err := mock.DeployContract(client, creation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The contract is gone before the block is handled.
+	if _, err := mock.CallContract(client, ca, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := client.BlockByNumber(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.handleBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	contract := new(dto.Contract)
+	if err := memdb.Get([]byte(ca.Hex()), contract); err != nil {
+		t.Fatal(err)
+	}
+	if !contract.FromInitCode || contract.Failed {
+		t.Fatalf("TestHandleDestroyedContract, want: (true false) got: (%v %v)", contract.FromInitCode, contract.Failed)
+	}
+}
+
+func TestGrindProxy(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "handler")
+		memdb     = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, memdb, cp)
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	if _, err := New(client, fetcher, engine, cp, &Config{AllowProxyContract: true, ProxyDetectors: []string{"unknown"}}); err == nil {
+		t.Fatal("TestGrindProxy, want: failed got: success")
+	}
+
+	s, err := New(client, fetcher, engine, cp, &Config{AllowProxyContract: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contracts, err := s.grindContract(common.Hash{}, common.HexToAddress(mock.PrecompiledContractEIP1967))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The admin (0xa2) is not a contract, so it is not ground.
+	if len(contracts) != 2 || contracts[1].address != common.HexToAddress("0xb2") {
+		t.Fatalf("TestGrindProxy, want: 2 got: %d", len(contracts))
+	}
+
+	proxy := contracts[0].data
+	if len(proxy.RelateAddress) != 1 || proxy.RelateAddress[0] != common.HexToAddress("0xb2").Hex() {
+		t.Fatalf("TestGrindProxy, want: [0xb2] got: %v", proxy.RelateAddress)
+	}
+
+	want := []dto.Relation{
+		{Address: common.HexToAddress("0xa2").Hex(), Role: "admin", Kind: dto.KindEOA},
+		{Address: common.HexToAddress("0xb2").Hex(), Role: "implementation", Kind: dto.KindContract},
+	}
+	if len(proxy.Relations) != len(want) {
+		t.Fatalf("TestGrindProxy, want: %v got: %v", want, proxy.Relations)
+	}
+	for i := range want {
+		if proxy.Relations[i] != want[i] {
+			t.Fatalf("TestGrindProxy, want: %v got: %v", want, proxy.Relations)
+		}
+	}
 }
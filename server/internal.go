@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dbadoy/grinder/pkg/ethclient"
+	"github.com/dbadoy/grinder/pkg/grinder"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// grindInternalContracts grinds the contracts deployed by other
+// contracts in the block, found in the call frames of its
+// transactions. The deploying contract is recorded as the parent.
+func (s *Server) grindInternalContracts(block *types.Block) ([]*pendingContract, error) {
+	frames, err := s.eth.TraceBlockByNumber(context.Background(), block.NumberU64())
+	if err != nil {
+		return nil, err
+	}
+
+	txs := block.Transactions()
+	if len(frames) != len(txs) {
+		return nil, fmt.Errorf("trace of block #%d has %d transactions, want %d", block.NumberU64(), len(frames), len(txs))
+	}
+
+	pendings := make([]*pendingContract, 0)
+	for i, frame := range frames {
+		if frame == nil || frame.Error != "" {
+			continue
+		}
+
+		// The top-level frame is the transaction itself, deployment
+		// transactions are handled by handleTransactions.
+		for _, call := range frame.Calls {
+			contracts, err := s.grindCreations(txs[i], call)
+			if err != nil {
+				return nil, err
+			}
+			pendings = append(pendings, contracts...)
+		}
+	}
+
+	return pendings, nil
+}
+
+func (s *Server) grindCreations(tx *types.Transaction, frame *ethclient.CallFrame) ([]*pendingContract, error) {
+	// A reverted frame discards the contracts created in it.
+	if frame.Error != "" {
+		return nil, nil
+	}
+
+	pendings := make([]*pendingContract, 0)
+
+	if frame.Type == vm.CREATE.String() || frame.Type == vm.CREATE2.String() {
+		contracts, err := s.grindContract(tx.Hash(), frame.To)
+		if err == grinder.ErrNotContract {
+			// The input of a creation frame is the creation code.
+			contracts, err = grindInitCode(tx.Hash(), frame.To, frame.Input)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(contracts) != 0 {
+			contracts[0].data.Parent = frame.From.Hex()
+			contracts[0].redeployable = true
+		}
+		pendings = append(pendings, contracts...)
+	}
+
+	for _, call := range frame.Calls {
+		contracts, err := s.grindCreations(tx, call)
+		if err != nil {
+			return nil, err
+		}
+		pendings = append(pendings, contracts...)
+	}
+
+	return pendings, nil
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dbadoy/grinder/pkg/checkpoint"
+	"github.com/dbadoy/grinder/pkg/database/memdb"
+	"github.com/dbadoy/grinder/pkg/ethclient/mock"
+	"github.com/dbadoy/grinder/server/cft"
+	"github.com/dbadoy/grinder/server/dto"
+	"github.com/dbadoy/grinder/server/fetcher"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var factories = []string{
+	// CALLDATASIZE PUSH1 0x00 PUSH1 0x00 CALLDATACOPY
+	// CALLDATASIZE PUSH1 0x00 PUSH1 0x00 CREATE STOP
+	"36600060003736" + "60006000f000",
+	// CALLDATASIZE PUSH1 0x00 PUSH1 0x00 CALLDATACOPY
+	// PUSH1 0x00 CALLDATASIZE PUSH1 0x00 PUSH1 0x00 CREATE2 STOP
+	"366000600037" + "6000366000" + "6000f500",
+}
+
+func TestHandleInternalContracts(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "internal")
+		memdb     = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, memdb, cp)
+
+		s, _ = New(client, fetcher, engine, cp, &Config{AllowInternalContract: true})
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	storage := common.Hex2Bytes(testset[0].bytecode)
+
+	for i, factory := range factories {
+		runtime := common.Hex2Bytes(factory)
+
+		// PUSH1 len DUP1 PUSH1 0x0b PUSH1 0x00 CODECOPY PUSH1 0x00 RETURN
+		creation := append([]byte{0x60, byte(len(runtime)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}, runtime...)
+
+		ca, err := mock.DeployContract(client, creation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		receipt, err := mock.CallContract(client, ca, storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		child := crypto.CreateAddress(ca, 1)
+		if i == 1 {
+			child = crypto.CreateAddress2(ca, common.Hash{}, crypto.Keccak256(storage))
+		}
+
+		for _, number := range []uint64{receipt.BlockNumber.Uint64() - 1, receipt.BlockNumber.Uint64()} {
+			block, err := client.BlockByNumber(context.Background(), number)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.handleBlock(block); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		contract := new(dto.Contract)
+		if err := memdb.Get([]byte(child.Hex()), contract); err != nil {
+			t.Fatalf("TestHandleInternalContracts, want: %s exist got: %v", child.Hex(), err)
+		}
+
+		if contract.Parent != ca.Hex() || len(contract.Candidates) != 2 {
+			t.Fatalf("TestHandleInternalContracts, want: (%s 2) got: (%s %d)", ca.Hex(), contract.Parent, len(contract.Candidates))
+		}
+	}
+}
+
+func TestRedeployedContract(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "internal")
+		memdb     = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, memdb, cp)
+
+		s, _ = New(client, fetcher, engine, cp, &Config{})
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	var (
+		top   = common.HexToAddress("0x01")
+		child = common.HexToAddress("0x02")
+	)
+
+	for _, ca := range []common.Address{top, child} {
+		if err := engine.Insert([]byte(ca.Hex()), &dto.Contract{TxHash: "first"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A deployment transaction can't deploy the address again.
+	pendings := []*pendingContract{{top, &dto.Contract{TxHash: "second"}, true, false}}
+	if err := s.writeContracts(pendings); err == nil {
+		t.Fatalf("TestRedeployedContract, want: error got: <nil>")
+	}
+
+	pendings = []*pendingContract{{child, &dto.Contract{TxHash: "second"}, true, true}}
+	if err := s.writeContracts(pendings); err != nil {
+		t.Fatal(err)
+	}
+
+	contract := new(dto.Contract)
+	if err := memdb.Get([]byte(child.Hex()), contract); err != nil || contract.TxHash != "second" {
+		t.Fatalf("TestRedeployedContract, want: second got: %s (%v)", contract.TxHash, err)
+	}
+
+	// The overwritten deployment is put back by the revert.
+	if err := s.revert(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := memdb.Get([]byte(child.Hex()), contract); err != nil || contract.TxHash != "first" {
+		t.Fatalf("TestRedeployedContract, want: first got: %s (%v)", contract.TxHash, err)
+	}
+}
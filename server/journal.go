@@ -1,21 +1,90 @@
 package server
 
-import "github.com/dbadoy/grinder/server/cft"
+import (
+	"github.com/dbadoy/grinder/pkg/database"
+	"github.com/dbadoy/grinder/server/cft"
+)
 
 // journalObject has contrasting methods for specific
 // behaviors. Stored data related to blockchain rarely
-// undergoes modifications, only additions. Therefore,
-// journalObject simply implements revert, and does
-// not define behaviors such as 'rollback(to previous
-// value)'.
+// undergoes modifications, mostly additions. The few
+// modifications (e.g. a proxy upgrade) keep the previous
+// value to put it back.
 type journalObject interface {
 	revert(engine cft.Engine) error
 }
 
-type insertContract struct {
-	key []byte
+// insertData deletes the inserted data.
+type insertData struct {
+	index string
+	key   []byte
 }
 
-func (i *insertContract) revert(engine cft.Engine) error {
-	return engine.Delete(i.key)
+func (i *insertData) revert(engine cft.Engine) error {
+	return engine.Delete(i.index, i.key)
+}
+
+// putData restores the data that was overwritten, or deletes the
+// data if there was none.
+type putData struct {
+	index string
+	key   []byte
+
+	// prev is the JSON encoding of the previous data, nil if the
+	// key did not exist.
+	prev []byte
+}
+
+func (p *putData) revert(engine cft.Engine) error {
+	if p.prev == nil {
+		return engine.Delete(p.index, p.key)
+	}
+	return engine.Put(p.key, database.NewRaw(p.index, p.prev))
+}
+
+// insert inserts the data, the insertion is journaled.
+func (s *Server) insert(key []byte, data database.Data) error {
+	journal := &insertData{data.Index(), key}
+
+	if s.wal != nil {
+		// An existing key fails to be inserted, it must not be
+		// deleted by the replay.
+		ok, err := s.engine.Exist(data.Index(), key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return database.ErrAlreadyExist
+		}
+
+		if err := s.prepare(journal); err != nil {
+			return err
+		}
+	}
+
+	if err := s.engine.Insert(key, data); err != nil {
+		return err
+	}
+	s.journals = append(s.journals, journal)
+
+	return nil
+}
+
+// putJournal returns the journal that restores the data currently
+// stored under the key.
+func (s *Server) putJournal(index string, key []byte) (*putData, error) {
+	var (
+		prev    = database.NewRaw(index, nil)
+		journal = &putData{index: index, key: key}
+	)
+
+	switch err := s.engine.Get(key, prev); err {
+	case nil:
+		journal.prev = prev.Value
+	case database.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	return journal, nil
 }
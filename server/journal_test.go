@@ -1,12 +1,22 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/dbadoy/grinder/pkg/checkpoint"
+	"github.com/dbadoy/grinder/pkg/database"
 	"github.com/dbadoy/grinder/pkg/database/memdb"
+	"github.com/dbadoy/grinder/pkg/ethclient/mock"
 	"github.com/dbadoy/grinder/server/cft"
 	"github.com/dbadoy/grinder/server/dto"
+	"github.com/dbadoy/grinder/server/fetcher"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestJournalRevert(t *testing.T) {
@@ -26,7 +36,7 @@ func TestJournalRevert(t *testing.T) {
 		key := []byte(fmt.Sprintf("%d", i))
 
 		engine.Insert(key, &dto.Contract{})
-		journals = append(journals, &insertContract{key})
+		journals = append(journals, &insertData{new(dto.Contract).Index(), key})
 	}
 
 	if mdb.Size() != n {
@@ -41,3 +51,237 @@ func TestJournalRevert(t *testing.T) {
 		t.Fatalf("TestJournalRevert, want: 0, got: %d", mdb.Size())
 	}
 }
+
+func TestJournalReplay(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "journal")
+		mdb       = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, mdb, cp)
+
+		cfg = &Config{JournalPath: filepath.Join(t.TempDir(), "journal")}
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	s, err := New(client, fetcher, engine, cp, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		stored  = common.HexToAddress("0x01")
+		written = common.HexToAddress("0x02")
+	)
+
+	if err := engine.Insert([]byte(stored.Hex()), &dto.Contract{TxHash: "stored"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server stops before the block is committed.
+	pendings := []*pendingContract{
+		{stored, &dto.Contract{}, false, false},
+		{written, &dto.Contract{}, true, false},
+	}
+	if err := s.writeContracts(pendings); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(client, fetcher, engine, cp, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := mdb.Exist(new(dto.Contract).Index(), []byte(written.Hex())); ok {
+		t.Fatalf("TestJournalReplay, want: not exist got: exist")
+	}
+	if ok, _ := mdb.Exist(new(dto.Contract).Index(), []byte(stored.Hex())); !ok {
+		t.Fatalf("TestJournalReplay, want: exist got: not exist")
+	}
+	if _, err := os.Stat(cfg.JournalPath); !os.IsNotExist(err) {
+		t.Fatalf("TestJournalReplay, want: removed got: %v", err)
+	}
+
+	// A committed block leaves nothing to replay.
+	if err := s.writeContracts(pendings[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(client, fetcher, engine, cp, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := mdb.Exist(new(dto.Contract).Index(), []byte(written.Hex())); !ok {
+		t.Fatalf("TestJournalReplay, want: exist got: not exist")
+	}
+}
+
+func TestJournalPut(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "journal")
+		mdb       = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, mdb, cp)
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	for _, cfg := range []*Config{{}, {JournalPath: filepath.Join(t.TempDir(), "journal")}} {
+		s, err := New(client, fetcher, engine, cp, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var (
+			stored = []byte(common.HexToAddress("0x01").Hex())
+			added  = []byte(common.HexToAddress("0x02").Hex())
+		)
+
+		if err := engine.Put(stored, &dto.Contract{TxHash: "stored"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.insert([]byte("abi"), &dto.ABI{}); err != nil {
+			t.Fatal(err)
+		}
+
+		b := s.newBatch()
+		for key, hash := range map[string]string{string(stored): "overwritten", string(added): "added"} {
+			prev, err := s.putJournal(new(dto.Contract).Index(), []byte(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.put([]byte(key), &dto.Contract{TxHash: hash}, prev)
+		}
+		if err := s.write(b, b.Write); err != nil {
+			t.Fatal(err)
+		}
+
+		contract := new(dto.Contract)
+		if err := mdb.Get(stored, contract); err != nil || contract.TxHash != "overwritten" {
+			t.Fatalf("TestJournalPut, want: overwritten got: %s (%v)", contract.TxHash, err)
+		}
+
+		// Without a journal file the journals are reverted in
+		// memory, otherwise by the next start.
+		if cfg.JournalPath == "" {
+			if err := s.revert(); err != nil {
+				t.Fatal(err)
+			}
+		} else if _, err := New(client, fetcher, engine, cp, cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := mdb.Get(stored, contract); err != nil || contract.TxHash != "stored" {
+			t.Fatalf("TestJournalPut, want: stored got: %s (%v)", contract.TxHash, err)
+		}
+		if ok, _ := mdb.Exist(contract.Index(), added); ok {
+			t.Fatalf("TestJournalPut, want: not exist got: exist")
+		}
+		if ok, _ := mdb.Exist(new(dto.ABI).Index(), []byte("abi")); ok {
+			t.Fatalf("TestJournalPut, want: not exist got: exist")
+		}
+	}
+}
+
+// failingEngine fails the deletes, and the commits after they are
+// written, while fail is set.
+type failingEngine struct {
+	cft.Engine
+	fail bool
+}
+
+func (e *failingEngine) Delete(index string, key []byte) error {
+	if e.fail {
+		return errors.New("delete failed")
+	}
+	return e.Engine.Delete(index, key)
+}
+
+func (e *failingEngine) Commit(batch database.Batch, n uint64) ([]error, error) {
+	results, err := e.Engine.Commit(batch, n)
+	if e.fail {
+		return nil, errors.New("commit failed")
+	}
+	return results, err
+}
+
+func TestJournalKept(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp      = checkpoint.New(checkpoint.DefaultBasePath, "journal")
+		mdb     = memdb.New()
+		fetcher = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		solo, _ = cft.NewSoloEngine(nil, mdb, cp)
+		engine  = &failingEngine{Engine: solo}
+
+		cfg = &Config{JournalPath: filepath.Join(t.TempDir(), "journal")}
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	s, err := New(client, fetcher, engine, cp, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := mock.DeployContract(client, common.Hex2Bytes(testset[0].bytecode))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := client.BlockByNumber(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The commit fails after the write, and so does the revert.
+	engine.fail = true
+	if err := s.handleBlock(block); err == nil {
+		t.Fatalf("TestJournalKept, want: error got: <nil>")
+	}
+	if ok, _ := mdb.Exist(new(dto.Contract).Index(), []byte(ca.Hex())); !ok {
+		t.Fatalf("TestJournalKept, want: exist got: not exist")
+	}
+
+	// The block is handled again and committed.
+	engine.fail = false
+	if err := s.handleBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Checkpoint() != 1 {
+		t.Fatalf("TestJournalKept, want: 1 got: %d", cp.Checkpoint())
+	}
+
+	// The restart must not revert the committed block.
+	if _, err := New(client, fetcher, engine, cp, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := mdb.Exist(new(dto.Contract).Index(), []byte(ca.Hex())); !ok {
+		t.Fatalf("TestJournalKept, want: exist got: not exist")
+	}
+	if ok, _ := mdb.Exist(checkpoint.MarkerIndex, checkpoint.MarkerKey); !ok {
+		t.Fatalf("TestJournalKept, want: exist got: not exist")
+	}
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/dbadoy/grinder/params"
+	"github.com/dbadoy/grinder/pkg/ethclient"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	emptySlot = make([]byte, 32)
+
+	minimalProxies = []struct {
+		prefix, suffix []byte
+
+		// metadata is set if the code continues after the suffix.
+		metadata bool
+	}{
+		{common.FromHex(params.MinimalProxyPrefixEIP1167), common.FromHex(params.MinimalProxySuffixEIP1167), false},
+		{common.FromHex(params.MetaProxyPrefixEIP3448), common.FromHex(params.MetaProxySuffixEIP3448), true},
+	}
+)
+
+// slot detects the proxies that keep the implementation address
+// in a storage slot. The address must hold code, and if the proxy
+// exposes a getter for it, the getter must agree with the slot.
+type slot struct {
+	name   string
+	slot   string
+	getter string
+	role   Role
+}
+
+func (d *slot) Name() string { return d.name }
+
+func (d *slot) Detect(ctx context.Context, eth ethclient.Client, ca common.Address) ([]Relation, error) {
+	value, err := eth.StorageAt(ctx, ca, common.HexToHash(d.slot), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	impl, ok := toAddress(value)
+	if !ok {
+		return nil, ErrNotProxy
+	}
+
+	if d.getter != "" {
+		res, err := eth.CallContract(ctx, ethereum.CallMsg{To: &ca, Data: common.FromHex(d.getter)}, nil)
+		if err != nil || !bytes.Equal(res, value) {
+			return nil, ErrNotProxy
+		}
+	}
+
+	code, err := eth.CodeAt(ctx, impl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(code) == 0 {
+		return nil, ErrNotProxy
+	}
+
+	return []Relation{{impl, d.role}}, nil
+}
+
+// eip1967 detects Transparent proxies. Either of the slots may be
+// empty, e.g. an ERC1967Proxy used for UUPS has no admin.
+type eip1967 struct{}
+
+func (eip1967) Name() string { return "eip1967" }
+
+func (eip1967) Detect(ctx context.Context, eth ethclient.Client, ca common.Address) ([]Relation, error) {
+	relations := make([]Relation, 0, 2)
+
+	for _, slot := range []struct {
+		slot string
+		role Role
+	}{
+		{params.AdminAddressSlotEIP1967, RoleAdmin},
+		{params.ImplementationAddressSlotEIP1967, RoleImplementation},
+	} {
+		value, err := eth.StorageAt(ctx, ca, common.HexToHash(slot.slot), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if addr, ok := toAddress(value); ok {
+			relations = append(relations, Relation{addr, slot.role})
+		}
+	}
+
+	if len(relations) == 0 {
+		return nil, ErrNotProxy
+	}
+	return relations, nil
+}
+
+// eip1967Beacon detects beacon proxies, and asks the beacon for
+// the implementation. Both the beacon and the implementation are
+// returned.
+type eip1967Beacon struct{}
+
+func (eip1967Beacon) Name() string { return "eip1967-beacon" }
+
+func (eip1967Beacon) Detect(ctx context.Context, eth ethclient.Client, ca common.Address) ([]Relation, error) {
+	value, err := eth.StorageAt(ctx, ca, common.HexToHash(params.BeaconAddressSlotEIP1967), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	beacon, ok := toAddress(value)
+	if !ok {
+		return nil, ErrNotProxy
+	}
+
+	impl, err := BeaconImplementation(ctx, eth, beacon)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Relation{{beacon, RoleBeacon}, {impl, RoleImplementation}}, nil
+}
+
+// BeaconImplementation calls implementation() on the beacon. If the
+// beacon does not answer with an address, ErrNotProxy is returned.
+func BeaconImplementation(ctx context.Context, eth ethclient.Client, beacon common.Address) (common.Address, error) {
+	res, err := eth.CallContract(ctx, ethereum.CallMsg{
+		To:   &beacon,
+		Data: common.FromHex(params.BeaconImplementationSelector),
+	}, nil)
+	if err != nil {
+		return common.Address{}, ErrNotProxy
+	}
+
+	impl, ok := toAddress(res)
+	if !ok {
+		return common.Address{}, ErrNotProxy
+	}
+	return impl, nil
+}
+
+// eip1167 detects minimal proxy (EIP-1167) and MetaProxy
+// (EIP-3448) clones, whose implementation address is hardcoded in
+// the code.
+type eip1167 struct{}
+
+func (eip1167) Name() string { return "eip1167" }
+
+func (eip1167) Detect(ctx context.Context, eth ethclient.Client, ca common.Address) ([]Relation, error) {
+	code, err := eth.CodeAt(ctx, ca, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	impl, ok := minimalProxyTarget(code)
+	if !ok {
+		return nil, ErrNotProxy
+	}
+
+	return []Relation{{impl, RoleImplementation}}, nil
+}
+
+// minimalProxyTarget returns the implementation address of the
+// minimal proxy code.
+func minimalProxyTarget(code []byte) (common.Address, bool) {
+	for _, proxy := range minimalProxies {
+		var (
+			prefix = proxy.prefix
+			suffix = proxy.suffix
+			end    = len(prefix) + common.AddressLength + len(suffix)
+		)
+
+		if len(code) < end || (len(code) != end && !proxy.metadata) {
+			continue
+		}
+
+		if !bytes.HasPrefix(code, prefix) || !bytes.Equal(code[end-len(suffix):end], suffix) {
+			continue
+		}
+
+		return common.BytesToAddress(code[len(prefix) : len(prefix)+common.AddressLength]), true
+	}
+
+	return common.Address{}, false
+}
+
+// toAddress decodes a 32-byte word holding a non-zero address.
+func toAddress(word []byte) (common.Address, bool) {
+	if len(word) != 32 || bytes.Equal(word, emptySlot) || !bytes.Equal(word[:12], emptySlot[:12]) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(word), true
+}
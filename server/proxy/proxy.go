@@ -0,0 +1,114 @@
+// Package proxy detects the contracts a proxy contract delegates
+// to. Each proxy pattern is a Detector, registered by name so that
+// the patterns to look for can be chosen per deployment.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dbadoy/grinder/params"
+	"github.com/dbadoy/grinder/pkg/ethclient"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrNotProxy = errors.New("not a proxy contract")
+)
+
+// DefaultDetectors are the detectors used if the configuration
+// does not name any. The other registered detectors read storage
+// slots that non-proxy contracts use too, so they are opt-in.
+var DefaultDetectors = []string{"eip1967", "eip1967-beacon", "eip1822", "eip1167"}
+
+// Role is the part an address plays for a proxy.
+type Role string
+
+const (
+	RoleAdmin          Role = "admin"
+	RoleImplementation Role = "implementation"
+	RoleLogic          Role = "logic"
+	RoleBeacon         Role = "beacon"
+)
+
+// Relation is an address a proxy relates to.
+type Relation struct {
+	Address common.Address
+	Role    Role
+}
+
+// Detector recognises a proxy pattern.
+type Detector interface {
+	// Name identifies the detector in the configuration.
+	Name() string
+
+	// Detect returns the addresses the contract at ca relates to,
+	// or ErrNotProxy if it does not follow the pattern.
+	Detect(ctx context.Context, eth ethclient.Client, ca common.Address) ([]Relation, error)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Detector)
+)
+
+// Register makes the detector available by its name. It panics if
+// a detector with the same name is already registered.
+func Register(d Detector) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := registry[d.Name()]; ok {
+		panic(fmt.Sprintf("proxy: detector %s registered twice", d.Name()))
+	}
+	registry[d.Name()] = d
+}
+
+// Lookup returns the registered detectors with the given names,
+// in the same order.
+func Lookup(names ...string) ([]Detector, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	detectors := make([]Detector, 0, len(names))
+	for _, name := range names {
+		d, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("proxy: unknown detector %s", name)
+		}
+		detectors = append(detectors, d)
+	}
+
+	return detectors, nil
+}
+
+// Names returns the names of the registered detectors.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func init() {
+	for _, d := range []Detector{
+		new(eip1967),
+		new(eip1967Beacon),
+		new(eip1167),
+		&slot{name: "eip1822", slot: params.LogicAddressSlotEIP1822, role: RoleLogic},
+		&slot{name: "gnosis-safe", slot: params.MasterCopySlotGnosisSafe, getter: params.MasterCopySelectorGnosisSafe, role: RoleImplementation},
+		&slot{name: "oz-legacy", slot: params.ImplementationSlotZeppelinOS, role: RoleImplementation},
+		&slot{name: "compound", slot: params.ComptrollerImplementationSlot, getter: params.ComptrollerImplementationSelector, role: RoleImplementation},
+	} {
+		Register(d)
+	}
+}
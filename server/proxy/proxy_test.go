@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/dbadoy/grinder/params"
+	"github.com/dbadoy/grinder/pkg/ethclient/mock"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Remix Storage.sol
+const storage = "608060405234801561001057600080fd5b50610150806100206000396000f3fe608060405234801561001057600080fd5b50600436106100365760003560e01c80632e64cec11461003b5780636057361d14610059575b600080fd5b610043610075565b60405161005091906100a1565b60405180910390f35b610073600480360381019061006e91906100ed565b61007e565b005b60008054905090565b8060008190555050565b6000819050919050565b61009b81610088565b82525050565b60006020820190506100b66000830184610092565b92915050565b600080fd5b6100ca81610088565b81146100d557600080fd5b50565b6000813590506100e7816100c1565b92915050565b600060208284031215610103576101026100bc565b5b6000610111848285016100d8565b9150509291505056fea2646970667358221220322c78243e61b783558509c9cc22cb8493dde6925aa5e89a08cdf6e22f279ef164736f6c63430008120033"
+
+func detect(t *testing.T, client *mock.Mock, name string, ca common.Address) ([]Relation, error) {
+	detectors, err := Lookup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return detectors[0].Detect(context.Background(), client, ca)
+}
+
+// deploySlotProxy deploys a contract that stores impl in the slot.
+// If getter is set, the contract returns the slot for any call,
+// otherwise it returns nothing.
+func deploySlotProxy(t *testing.T, client *mock.Mock, slot string, impl common.Address, getter bool) common.Address {
+	// STOP
+	runtime := "00"
+	if getter {
+		// PUSH32 slot SLOAD PUSH1 0x00 MSTORE PUSH1 0x20 PUSH1 0x00 RETURN
+		runtime = "7f" + common.Bytes2Hex(common.HexToHash(slot).Bytes()) + "54" + "600052" + "60206000f3"
+	}
+
+	// PUSH20 impl PUSH32 slot SSTORE
+	// PUSH1 len DUP1 PUSH1 0x42 PUSH1 0x00 CODECOPY PUSH1 0x00 RETURN
+	creation := "73" + common.Bytes2Hex(impl.Bytes()) + "7f" + common.Bytes2Hex(common.HexToHash(slot).Bytes()) + "55" +
+		fmt.Sprintf("60%02x", len(runtime)/2) + "80" + "6042" + "6000" + "39" + "6000" + "f3" + runtime
+
+	ca, err := mock.DeployContract(client, common.Hex2Bytes(creation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+func TestRegistry(t *testing.T) {
+	if _, err := Lookup(DefaultDetectors...); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Lookup("eip1967", "unknown"); err == nil {
+		t.Fatal("TestRegistry, want: failed got: success")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("TestRegistry, want: panic got: none")
+		}
+	}()
+	Register(new(eip1967))
+}
+
+func TestEIP1822(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := detect(t, client, "eip1822", common.HexToAddress(mock.PrecompiledContractEIP1822))
+	if err != nil {
+		t.Fatalf("TestEIP1822, want: sucess got: failed (%v)", err)
+	}
+	if len(addrs) != 1 || addrs[0] != (Relation{common.HexToAddress("0xa1"), RoleLogic}) {
+		t.Fatalf("TestEIP1822, want: [0xa1] got: %v", addrs)
+	}
+}
+
+func TestEIP1967(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := detect(t, client, "eip1967", common.HexToAddress(mock.PrecompiledContractEIP1967))
+	if err != nil {
+		t.Fatalf("TestEIP1967, want: sucess got: failed (%v)", err)
+	}
+	if len(addrs) != 2 || addrs[0].Role != RoleAdmin || addrs[1].Role != RoleImplementation {
+		t.Fatalf("TestEIP1967, want: [admin implementation] got: %v", addrs)
+	}
+
+	// A beacon proxy has neither the admin nor the implementation slot.
+	if _, err := detect(t, client, "eip1967", common.HexToAddress(mock.PrecompiledContractBeacon)); err != ErrNotProxy {
+		t.Fatalf("TestEIP1967, want: %v got: %v", ErrNotProxy, err)
+	}
+}
+
+func TestEIP1967Beacon(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := detect(t, client, "eip1967-beacon", common.HexToAddress(mock.PrecompiledContractBeacon))
+	if err != nil {
+		t.Fatalf("TestEIP1967Beacon, want: sucess got: failed (%v)", err)
+	}
+
+	if len(addrs) != 2 || addrs[0] != (Relation{common.HexToAddress("0xbc"), RoleBeacon}) || addrs[1] != (Relation{common.HexToAddress("0xb3"), RoleImplementation}) {
+		t.Fatalf("TestEIP1967Beacon, want: [0xbc 0xb3] got: %v", addrs)
+	}
+
+	if _, err := detect(t, client, "eip1967-beacon", common.HexToAddress(mock.PrecompiledContractEIP1967)); err != ErrNotProxy {
+		t.Fatalf("TestEIP1967Beacon, want: %v got: %v", ErrNotProxy, err)
+	}
+}
+
+func TestEIP1167(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	impl, err := mock.DeployContract(client, common.Hex2Bytes(storage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		clone = "363d3d373d3d3d363d73" + common.Bytes2Hex(impl.Bytes()) + "5af43d82803e903d91602b57fd5bf3"
+		meta  = "363d3d373d3d3d3d60368038038091363936013d73" + common.Bytes2Hex(impl.Bytes()) + "5af43d3d93803e603457fd5bf3" +
+			common.Bytes2Hex(common.LeftPadBytes([]byte{0x01}, 32)) + common.Bytes2Hex(common.LeftPadBytes([]byte{0x20}, 32))
+
+		creations = []string{
+			// RETURNDATASIZE PUSH1 0x2d DUP1 PUSH1 0x0a RETURNDATASIZE CODECOPY DUP2 RETURN
+			"3d602d80600a3d3981f3" + clone,
+			// PUSH1 0x0b CODESIZE SUB DUP1 PUSH1 0x0b RETURNDATASIZE CODECOPY RETURNDATASIZE RETURN
+			"600b380380600b3d393df3" + meta,
+		}
+	)
+
+	for _, creation := range creations {
+		ca, err := mock.DeployContract(client, common.Hex2Bytes(creation))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addrs, err := detect(t, client, "eip1167", ca)
+		if err != nil {
+			t.Fatalf("TestEIP1167, want: success got: failed (%v)", err)
+		}
+		if len(addrs) != 1 || addrs[0] != (Relation{impl, RoleImplementation}) {
+			t.Fatalf("TestEIP1167, want: [%s] got: %v", impl, addrs)
+		}
+	}
+
+	if _, err := detect(t, client, "eip1167", impl); err != ErrNotProxy {
+		t.Fatalf("TestEIP1167, want: %v got: %v", ErrNotProxy, err)
+	}
+}
+
+func TestSlotDetectors(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	impl, err := mock.DeployContract(client, common.Hex2Bytes(storage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		slot string
+	}{
+		{"gnosis-safe", params.MasterCopySlotGnosisSafe},
+		{"oz-legacy", params.ImplementationSlotZeppelinOS},
+		{"compound", params.ComptrollerImplementationSlot},
+	}
+
+	for _, test := range tests {
+		ca := deploySlotProxy(t, client, test.slot, impl, true)
+
+		addrs, err := detect(t, client, test.name, ca)
+		if err != nil {
+			t.Fatalf("TestSlotDetectors - %s, want: success got: failed (%v)", test.name, err)
+		}
+		if len(addrs) != 1 || addrs[0] != (Relation{impl, RoleImplementation}) {
+			t.Fatalf("TestSlotDetectors - %s, want: [%s] got: %v", test.name, impl, addrs)
+		}
+
+		// The slot must hold a contract.
+		ca = deploySlotProxy(t, client, test.slot, common.HexToAddress("0xdead"), true)
+		if _, err := detect(t, client, test.name, ca); err != ErrNotProxy {
+			t.Fatalf("TestSlotDetectors - %s, want: %v got: %v", test.name, ErrNotProxy, err)
+		}
+	}
+
+	// e.g. Ownable keeps the owner in the first slot, but does not
+	// answer masterCopy().
+	ca := deploySlotProxy(t, client, params.MasterCopySlotGnosisSafe, impl, false)
+	if _, err := detect(t, client, "gnosis-safe", ca); err != ErrNotProxy {
+		t.Fatalf("TestSlotDetectors, want: %v got: %v", ErrNotProxy, err)
+	}
+}
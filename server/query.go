@@ -0,0 +1,51 @@
+package server
+
+import (
+	"github.com/dbadoy/grinder/pkg/database"
+	"github.com/dbadoy/grinder/server/dto"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// candidatesField is the dto.Contract field that the interface
+// match is performed on.
+const candidatesField = "Candidates"
+
+// Contracts returns every stored contract whose candidates contain
+// all of the given method and event IDs.
+func (s *Server) Contracts(ids []string) (map[common.Address]*dto.Contract, error) {
+	if len(ids) == 0 {
+		return nil, database.ErrEmptyQuery
+	}
+
+	terms := make([]string, 0, len(ids))
+	for _, id := range ids {
+		terms = append(terms, dto.NormalizeID(id))
+	}
+
+	docs, err := s.engine.Search(new(dto.Contract).Index(), candidatesField, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[common.Address]*dto.Contract, len(docs))
+	for _, doc := range docs {
+		contract := new(dto.Contract)
+		if err := doc.Decode(contract); err != nil {
+			return nil, err
+		}
+		res[common.HexToAddress(string(doc.Key))] = contract
+	}
+
+	return res, nil
+}
+
+// ContractsByABI returns every stored contract that satisfies the
+// interface registered under the given name.
+func (s *Server) ContractsByABI(name string) (map[common.Address]*dto.Contract, error) {
+	abi := new(dto.ABI)
+	if err := s.engine.Get([]byte(name), abi); err != nil {
+		return nil, err
+	}
+
+	return s.Contracts(abi.IDs())
+}
@@ -2,11 +2,14 @@ package server
 
 import (
 	"errors"
+	"log"
 
 	"github.com/dbadoy/grinder/pkg/checkpoint"
+	"github.com/dbadoy/grinder/pkg/database"
 	"github.com/dbadoy/grinder/pkg/ethclient"
 	"github.com/dbadoy/grinder/server/cft"
 	"github.com/dbadoy/grinder/server/fetcher"
+	"github.com/dbadoy/grinder/server/proxy"
 )
 
 var (
@@ -20,7 +23,10 @@ type Server struct {
 
 	fetcher *fetcher.Fetcher
 
+	detectors []proxy.Detector
+
 	journals []journalObject
+	wal      *wal // nil if Config.JournalPath is empty
 
 	// main loop
 	req  chan request
@@ -34,16 +40,36 @@ func New(eth ethclient.Client, fetcher *fetcher.Fetcher, engine cft.Engine, cp c
 		return nil, err
 	}
 
-	return &Server{
-		engine:   engine,
-		eth:      eth,
-		cp:       cp,
-		fetcher:  fetcher,
-		journals: make([]journalObject, 0),
-		req:      make(chan request),
-		quit:     make(chan struct{}),
-		cfg:      cfg,
-	}, nil
+	detectors, _ := cfg.proxyDetectors()
+
+	// The journals left by a previous run are reverted before any
+	// block is handled.
+	var w *wal
+	if cfg.JournalPath != "" {
+		w = newWAL(cfg.JournalPath)
+		if err := w.replay(engine); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Server{
+		engine:    engine,
+		eth:       eth,
+		cp:        cp,
+		fetcher:   fetcher,
+		detectors: detectors,
+		journals:  make([]journalObject, 0),
+		wal:       w,
+		req:       make(chan request),
+		quit:      make(chan struct{}),
+		cfg:       cfg,
+	}
+
+	if err := s.reconcile(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
 }
 
 func (s *Server) Run() {
@@ -97,13 +123,46 @@ func (s *Server) AddContractSync(req *ContractRequest) error {
 	return <-req.errc
 }
 
+// reconcile advances the checkpoint to the marker stored with the
+// documents. The checkpoint falls behind if it failed to be
+// advanced after a block was committed.
+//
+// The marker of a block that failed to be reverted is reverted
+// first, the block is not committed.
+func (s *Server) reconcile() error {
+	if err := s.retryReverts(); err != nil {
+		return err
+	}
+
+	marker := new(checkpoint.Marker)
+	if err := s.engine.Get(checkpoint.MarkerKey, marker); err != nil {
+		if err == database.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if marker.Number > s.engine.Checkpoint() {
+		return s.engine.SetCheckpoint(marker.Number)
+	}
+	return nil
+}
+
 func (s *Server) loop() {
 	for {
 		select {
 		case block := <-s.fetcher.C:
+			// A block that is already committed is skipped.
+			if err := s.reconcile(); err != nil {
+				log.Printf("checkpoint reconcile failed: %v", err)
+				continue
+			}
+
 			if s.engine.Checkpoint()+1 == block.NumberU64() {
-				if err := s.handleBlock(block); err == nil {
-					s.engine.Increase()
+				// The fetcher sends the block again, since the
+				// checkpoint has not moved.
+				if err := s.handleBlock(block); err != nil {
+					log.Printf("block #%d failed: %v", block.NumberU64(), err)
 				}
 			}
 
@@ -58,7 +58,10 @@ func TestAddABI(t *testing.T) {
 	s.AddABI(&ABIRequest{Name: "test-2", ABI: input})
 	s.AddABI(&ABIRequest{Name: "test-3", ABI: input})
 
-	abi := memdb.Get([]byte("test")).(*dto.ABI)
+	abi := new(dto.ABI)
+	if err := memdb.Get([]byte("test"), abi); err != nil {
+		t.Fatal(err)
+	}
 	if len(abi.MethodIDs) != 2 || len(abi.EventIDs) != 2 {
 		t.Fatalf("TestAddABI, want: (MethodIDs 2 EventIDs 2) got: (MethodIDs %d EventIDs %d)", len(abi.MethodIDs), len(abi.EventIDs))
 	}
@@ -94,7 +97,10 @@ func TestAddABISync(t *testing.T) {
 			panic(err)
 		}
 
-		abi := memdb.Get([]byte("test")).(*dto.ABI)
+		abi := new(dto.ABI)
+		if err := memdb.Get([]byte("test"), abi); err != nil {
+			panic(err)
+		}
 		if len(abi.MethodIDs) != 2 || len(abi.EventIDs) != 2 {
 			panic(fmt.Errorf("TestAddABI, want: (MethodIDs 2 EventIDs 2) got: (MethodIDs %d EventIDs %d)", len(abi.MethodIDs), len(abi.EventIDs)))
 		}
@@ -146,7 +152,10 @@ func TestAddContract(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	contract := memdb.Get([]byte(ca.Hex())).(*dto.Contract)
+	contract := new(dto.Contract)
+	if err := memdb.Get([]byte(ca.Hex()), contract); err != nil {
+		t.Fatal(err)
+	}
 	if len(contract.Candidates) != 2 {
 		t.Fatalf("TestAddContract, want: 2 got: %d", len(contract.Candidates))
 	}
@@ -184,9 +193,12 @@ func TestAddContractSync(t *testing.T) {
 			panic(err)
 		}
 
-		contract := memdb.Get([]byte(ca.Hex())).(*dto.Contract)
-		if len(contract.Candidates) != 5 {
-			panic(fmt.Errorf("TestAddContract, want: 5 got: %d", len(contract.Candidates)))
+		contract := new(dto.Contract)
+		if err := memdb.Get([]byte(ca.Hex()), contract); err != nil {
+			panic(err)
+		}
+		if len(contract.Candidates) != 2 {
+			panic(fmt.Errorf("TestAddContract, want: 2 got: %d", len(contract.Candidates)))
 		}
 	}()
 
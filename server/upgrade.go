@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dbadoy/grinder/params"
+	"github.com/dbadoy/grinder/server/dto"
+	"github.com/dbadoy/grinder/server/proxy"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	upgradedEvent       = common.HexToHash(params.UpgradedEventEIP1967)
+	adminChangedEvent   = common.HexToHash(params.AdminChangedEventEIP1967)
+	beaconUpgradedEvent = common.HexToHash(params.BeaconUpgradedEventEIP1967)
+)
+
+// handleUpgrades follows the EIP-1967 events of the block, so the
+// relations of the stored proxies stay up to date after an upgrade.
+func (s *Server) handleUpgrades(b *writeBatch, number uint64) error {
+	if !s.cfg.AllowProxyContract {
+		return nil
+	}
+
+	logs, err := s.eth.BlockLogsByNumber(context.Background(), number)
+	if err != nil {
+		return err
+	}
+
+	for _, log := range logs {
+		relation, ok := decodeUpgrade(&log)
+		if !ok {
+			continue
+		}
+
+		if err := s.upgradeProxy(b, &log, relation); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeUpgrade returns the new relation announced by the log.
+func decodeUpgrade(log *types.Log) (proxy.Relation, bool) {
+	if log.Removed || len(log.Topics) == 0 {
+		return proxy.Relation{}, false
+	}
+
+	switch log.Topics[0] {
+	case upgradedEvent:
+		if len(log.Topics) == 2 {
+			return proxy.Relation{Address: common.BytesToAddress(log.Topics[1].Bytes()), Role: proxy.RoleImplementation}, true
+		}
+
+	case beaconUpgradedEvent:
+		if len(log.Topics) == 2 {
+			return proxy.Relation{Address: common.BytesToAddress(log.Topics[1].Bytes()), Role: proxy.RoleBeacon}, true
+		}
+
+	case adminChangedEvent:
+		// Neither of the admins is indexed.
+		if len(log.Data) == 64 {
+			return proxy.Relation{Address: common.BytesToAddress(log.Data[32:]), Role: proxy.RoleAdmin}, true
+		}
+	}
+
+	return proxy.Relation{}, false
+}
+
+// upgradeProxy replaces the relation of the stored proxy that has
+// the same role, grinds the new contract and records the upgrade.
+// Proxies that are not stored are ignored. The proxy may have been
+// queued by the same block, it is then updated in the batch.
+func (s *Server) upgradeProxy(b *writeBatch, log *types.Log, relation proxy.Relation) error {
+	var (
+		key     = []byte(log.Address.Hex())
+		prev    = new(dto.Contract)
+		journal *putData
+	)
+
+	if queued, ok := b.contracts[string(key)]; ok {
+		prev = queued
+	} else {
+		var err error
+		if journal, err = s.putJournal(prev.Index(), key); err != nil {
+			return err
+		}
+		if journal.prev == nil {
+			return nil
+		}
+		if err := json.Unmarshal(journal.prev, prev); err != nil {
+			return err
+		}
+	}
+
+	relations := []proxy.Relation{relation}
+	if relation.Role == proxy.RoleBeacon {
+		if impl, err := proxy.BeaconImplementation(context.Background(), s.eth, relation.Address); err == nil {
+			relations = append(relations, proxy.Relation{Address: impl, Role: proxy.RoleImplementation})
+		}
+	}
+
+	var (
+		contract = *prev
+		pendings = make([]*pendingContract, 0, len(relations))
+	)
+
+	contract.Relations = append([]dto.Relation(nil), prev.Relations...)
+	contract.Upgrades = append([]dto.Upgrade(nil), prev.Upgrades...)
+
+	for _, relation := range relations {
+		code, err := s.eth.CodeAt(context.Background(), relation.Address, nil)
+		if err != nil {
+			return err
+		}
+
+		kind := dto.KindContract
+		if len(code) == 0 {
+			kind = dto.KindEOA
+		}
+
+		contract.Relations = replaceRelation(contract.Relations, dto.Relation{
+			Address: relation.Address.Hex(),
+			Role:    string(relation.Role),
+			Kind:    kind,
+		})
+
+		upgrade := dto.Upgrade{Block: log.BlockNumber, Role: string(relation.Role), Address: relation.Address.Hex()}
+		if !containsUpgrade(contract.Upgrades, upgrade) {
+			contract.Upgrades = append(contract.Upgrades, upgrade)
+		}
+
+		if kind == dto.KindEOA {
+			continue
+		}
+
+		related, err := grindCode(log.TxHash, code)
+		if err != nil {
+			return err
+		}
+		pendings = append(pendings, &pendingContract{relation.Address, related, false, false})
+	}
+
+	contract.RelateAddress = nil
+	for _, relation := range contract.Relations {
+		if relation.Kind == dto.KindContract {
+			contract.RelateAddress = append(contract.RelateAddress, relation.Address)
+		}
+	}
+
+	if err := s.queueContracts(b, pendings); err != nil {
+		return err
+	}
+
+	if journal == nil {
+		*prev = contract
+		return nil
+	}
+
+	b.put(key, &contract, journal)
+	b.contracts[string(key)] = &contract
+
+	return nil
+}
+
+// replaceRelation replaces the relations with the same role.
+func replaceRelation(relations []dto.Relation, relation dto.Relation) []dto.Relation {
+	res := make([]dto.Relation, 0, len(relations)+1)
+	for _, r := range relations {
+		if r.Role != relation.Role {
+			res = append(res, r)
+		}
+	}
+	return append(res, relation)
+}
+
+func containsUpgrade(upgrades []dto.Upgrade, upgrade dto.Upgrade) bool {
+	for _, u := range upgrades {
+		if u == upgrade {
+			return true
+		}
+	}
+	return false
+}
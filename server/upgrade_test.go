@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dbadoy/grinder/params"
+	"github.com/dbadoy/grinder/pkg/checkpoint"
+	"github.com/dbadoy/grinder/pkg/database/memdb"
+	"github.com/dbadoy/grinder/pkg/ethclient/mock"
+	"github.com/dbadoy/grinder/server/cft"
+	"github.com/dbadoy/grinder/server/dto"
+	"github.com/dbadoy/grinder/server/fetcher"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// upgradeableProxy stores the address in the calldata as the
+// implementation, and emits Upgraded(address).
+//
+// PUSH1 0x00 CALLDATALOAD DUP1 PUSH32 slot SSTORE
+// PUSH32 Upgraded PUSH1 0x00 PUSH1 0x00 LOG2 STOP
+var upgradeableProxy = "600035" + "80" + "7f" + params.ImplementationAddressSlotEIP1967[2:] + "55" +
+	"7f" + params.UpgradedEventEIP1967[2:] + "6000" + "6000" + "a2" + "00"
+
+func TestHandleUpgrades(t *testing.T) {
+	client, err := mock.New(mock.DefaultPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		cp        = checkpoint.New(checkpoint.DefaultBasePath, "upgrade")
+		memdb     = memdb.New()
+		fetcher   = fetcher.New(client, cp, &fetcher.Config{PollInterval: 24 * time.Hour})
+		engine, _ = cft.NewSoloEngine(nil, memdb, cp)
+
+		s, _ = New(client, fetcher, engine, cp, &Config{AllowProxyContract: true})
+	)
+
+	defer func() {
+		os.RemoveAll(checkpoint.DefaultBasePath)
+	}()
+
+	handleBlock := func(number uint64) {
+		block, err := client.BlockByNumber(context.Background(), number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.handleBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var impls [2]common.Address
+	for i := range impls {
+		if impls[i], err = mock.DeployContract(client, common.Hex2Bytes(testset[0].bytecode)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// PUSH1 len DUP1 PUSH1 0x0b PUSH1 0x00 CODECOPY PUSH1 0x00 RETURN
+	ca, err := mock.DeployContract(client, common.Hex2Bytes("604d80600b6000396000f3"+upgradeableProxy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handleBlock(3)
+
+	for i, impl := range impls {
+		if _, err := mock.CallContract(client, ca, common.LeftPadBytes(impl.Bytes(), 32)); err != nil {
+			t.Fatal(err)
+		}
+		handleBlock(uint64(4 + i))
+	}
+
+	contract := new(dto.Contract)
+	if err := memdb.Get([]byte(ca.Hex()), contract); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(contract.RelateAddress) != 1 || contract.RelateAddress[0] != impls[1].Hex() {
+		t.Fatalf("TestHandleUpgrades, want: [%s] got: %v", impls[1].Hex(), contract.RelateAddress)
+	}
+
+	if len(contract.Upgrades) != 2 || contract.Upgrades[0].Block != 4 || contract.Upgrades[1].Address != impls[1].Hex() {
+		t.Fatalf("TestHandleUpgrades, want: 2 upgrades got: %v", contract.Upgrades)
+	}
+
+	for _, impl := range impls {
+		if ok, _ := memdb.Exist(contract.Index(), []byte(impl.Hex())); !ok {
+			t.Fatalf("TestHandleUpgrades, want: %s exist got: not exist", impl.Hex())
+		}
+	}
+
+	// AdminChanged(0x00, 0xa2), 0xa2 is an EOA.
+	log := &types.Log{
+		Address:     ca,
+		Topics:      []common.Hash{adminChangedEvent},
+		Data:        append(make([]byte, 32), common.LeftPadBytes(common.HexToAddress("0xa2").Bytes(), 32)...),
+		BlockNumber: 6,
+	}
+
+	relation, ok := decodeUpgrade(log)
+	if !ok {
+		t.Fatal("TestHandleUpgrades, want: AdminChanged got: none")
+	}
+	b := s.newBatch()
+	if err := s.upgradeProxy(b, log, relation); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write(b, b.Write); err != nil {
+		t.Fatal(err)
+	}
+
+	upgraded := new(dto.Contract)
+	if err := memdb.Get([]byte(ca.Hex()), upgraded); err != nil {
+		t.Fatal(err)
+	}
+	if len(upgraded.Relations) != 2 || upgraded.Relations[1].Kind != dto.KindEOA || len(upgraded.RelateAddress) != 1 {
+		t.Fatalf("TestHandleUpgrades, want: EOA admin got: %v", upgraded.Relations)
+	}
+
+	// The revert puts back the previous relations.
+	if err := s.revert(); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted := new(dto.Contract)
+	if err := memdb.Get([]byte(ca.Hex()), reverted); err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted.Relations) != 1 || len(reverted.Upgrades) != 2 {
+		t.Fatalf("TestHandleUpgrades, want: (1 2) got: (%d %d)", len(reverted.Relations), len(reverted.Upgrades))
+	}
+}
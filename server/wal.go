@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dbadoy/grinder/pkg/database"
+	"github.com/dbadoy/grinder/server/cft"
+)
+
+// Kinds of the persisted journals.
+const (
+	walInsert = "insert"
+	walPut    = "put"
+)
+
+// walEntry is the persisted form of a journalObject.
+type walEntry struct {
+	Kind  string          `json:"kind"`
+	Index string          `json:"index"`
+	Key   []byte          `json:"key"`
+	Prev  json.RawMessage `json:"prev,omitempty"`
+}
+
+// wal persists the journals of the ongoing block or request before
+// the writes are made. If the server stops before the journals are
+// committed or reverted, they are reverted by the next start.
+//
+// The file only exists while there are uncommitted journals.
+type wal struct {
+	path string
+	f    *os.File
+
+	// kept are the journals that failed to be reverted. They stay
+	// in the file until a retry or the next start reverts them.
+	kept []journalObject
+}
+
+func newWAL(path string) *wal {
+	return &wal{path: path}
+}
+
+// append writes the journals and syncs them to the disk.
+func (w *wal) append(journals ...journalObject) error {
+	if len(journals) == 0 {
+		return nil
+	}
+
+	if w.f == nil {
+		f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		w.f = f
+	}
+
+	for _, journal := range journals {
+		b, err := json.Marshal(encodeJournal(journal))
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.f.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return w.f.Sync()
+}
+
+// keep leaves the journals in the file after the next reset.
+func (w *wal) keep(journals ...journalObject) {
+	w.kept = append(w.kept, journals...)
+}
+
+// reset drops the journals, except for the kept ones.
+func (w *wal) reset() error {
+	if w.f != nil {
+		w.f.Close()
+		w.f = nil
+	}
+
+	if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return w.append(w.kept...)
+}
+
+// retry reverts the kept journals, from the last one, and drops
+// them once they are all reverted.
+func (w *wal) retry(engine cft.Engine) error {
+	if err := revertAll(engine, w.kept); err != nil {
+		return err
+	}
+
+	w.kept = nil
+	return w.reset()
+}
+
+// replay reverts the journals left by a previous run, from the last
+// one, and removes the file.
+func (w *wal) replay(engine cft.Engine) error {
+	journals, err := w.read()
+	if err != nil {
+		return err
+	}
+
+	if err := revertAll(engine, journals); err != nil {
+		return fmt.Errorf("journal replay failed: %v", err)
+	}
+
+	// The kept journals are in the file, they are reverted too.
+	w.kept = nil
+	return w.reset()
+}
+
+func revertAll(engine cft.Engine, journals []journalObject) error {
+	for i := len(journals) - 1; i >= 0; i-- {
+		// The write may not have been made, or the revert may
+		// have been made before it failed to be recorded.
+		if err := journals[i].revert(engine); err != nil && err != database.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *wal) read() ([]journalObject, error) {
+	f, err := os.Open(w.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		journals = make([]journalObject, 0)
+		scanner  = bufio.NewScanner(f)
+	)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	for scanner.Scan() {
+		var entry walEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// The last line is torn if the server stopped while
+			// writing it, its write was never made.
+			break
+		}
+
+		journal, err := decodeJournal(&entry)
+		if err != nil {
+			return nil, err
+		}
+		journals = append(journals, journal)
+	}
+
+	return journals, scanner.Err()
+}
+
+func encodeJournal(journal journalObject) *walEntry {
+	switch j := journal.(type) {
+	case *insertData:
+		return &walEntry{Kind: walInsert, Index: j.index, Key: j.key}
+	case *putData:
+		return &walEntry{Kind: walPut, Index: j.index, Key: j.key, Prev: j.prev}
+	default:
+		panic(fmt.Sprintf("bad journal: %T", journal))
+	}
+}
+
+func decodeJournal(entry *walEntry) (journalObject, error) {
+	switch entry.Kind {
+	case walInsert:
+		return &insertData{entry.Index, entry.Key}, nil
+	case walPut:
+		return &putData{entry.Index, entry.Key, entry.Prev}, nil
+	default:
+		return nil, fmt.Errorf("invalid journal kind: %s", entry.Kind)
+	}
+}